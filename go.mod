@@ -0,0 +1,12 @@
+module github.com/seedstack/tools
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v0.3.0
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.14.0 // indirect
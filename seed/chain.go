@@ -0,0 +1,154 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// stateDir is the directory where seed keeps its state in a project.
+// It is never walked by the transformations.
+const stateDir = transform.StateDir
+
+const migrationStateFile = "migrations.yml"
+
+var migrationRegexp = regexp.MustCompile(`^(\d+)`)
+
+// migration is a numbered transformation file of a migration chain.
+type migration struct {
+	Number int
+	Name   string
+	Path   string
+}
+
+// migrationState records the migrations applied on a project.
+type migrationState struct {
+	Applied []appliedMigration
+}
+
+type appliedMigration struct {
+	Name string
+	Date string
+}
+
+// listMigrations returns the transformation files of a migration chain
+// sorted by number. Their names must start with a number, for instance
+// "001-maven.yml" or "2_java.toml", and two files can't have the same number.
+func listMigrations(dir string) ([]migration, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if _, err := transform.Format(info.Name()); err != nil {
+			continue
+		}
+		match := migrationRegexp.FindStringSubmatch(info.Name())
+		if match == nil {
+			return nil, fmt.Errorf("the migration %s should start with a number", info.Name())
+		}
+		number, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Number: number, Name: info.Name(), Path: filepath.Join(dir, info.Name())})
+	}
+
+	sort.Sort(byNumber(migrations))
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Number == migrations[i-1].Number {
+			return nil, fmt.Errorf("the migrations %s and %s have the same number", migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+	return migrations, nil
+}
+
+type byNumber []migration
+
+func (m byNumber) Len() int           { return len(m) }
+func (m byNumber) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byNumber) Less(i, j int) bool { return m[i].Number < m[j].Number }
+
+// readMigrationState reads the state of the given project. A project
+// without state file doesn't have any migration applied.
+func readMigrationState(project string) (migrationState, error) {
+	var state migrationState
+	dat, err := ioutil.ReadFile(filepath.Join(project, stateDir, migrationStateFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = yaml.Unmarshal(dat, &state)
+	return state, err
+}
+
+func writeMigrationState(project string, state migrationState) error {
+	dat, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(project, stateDir), 0755); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(project, stateDir, migrationStateFile), dat)
+}
+
+// markApplied records the migration as applied now.
+func (s *migrationState) markApplied(m migration) {
+	s.Applied = append(s.Applied, appliedMigration{Name: m.Name, Date: time.Now().Format(time.RFC3339)})
+}
+
+func (s migrationState) isApplied(m migration) bool {
+	for _, applied := range s.Applied {
+		if applied.Name == m.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// level returns the name of the last applied migration of the chain,
+// or an empty string if none was applied.
+func (s migrationState) level(migrations []migration) string {
+	level := ""
+	for _, m := range migrations {
+		if s.isApplied(m) {
+			level = m.Name
+		}
+	}
+	return level
+}
+
+// pendingMigrations returns the migrations of the chain which are not
+// applied yet, in order.
+func pendingMigrations(migrations []migration, state migrationState) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if !state.isApplied(m) {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestListMigrations(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{
+		"10-config.yml":   "",
+		"002-java.toml":   "",
+		"1_maven.yml":     "",
+		"README.md":       "",
+		"parts/other.yml": "",
+	})
+	defer os.RemoveAll(dir)
+
+	migrations, err := listMigrations(dir)
+	if err != nil {
+		t.Fatalf("No error was expected but found %v", err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("3 migrations were expected but found %v", migrations)
+	}
+	if migrations[0].Name != "1_maven.yml" || migrations[1].Name != "002-java.toml" || migrations[2].Name != "10-config.yml" {
+		t.Errorf("The migrations should be sorted by number but found %v", migrations)
+	}
+}
+
+func TestListInvalidMigrations(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"1-maven.yml": "", "maven.yml": ""})
+	defer os.RemoveAll(dir)
+
+	if _, err := listMigrations(dir); err == nil {
+		t.Error("A migration without number should be rejected")
+	}
+
+	dir2 := writeTdfs(t, map[string]string{"1-maven.yml": "", "01-java.yml": ""})
+	defer os.RemoveAll(dir2)
+
+	if _, err := listMigrations(dir2); err == nil {
+		t.Error("Two migrations with the same number should be rejected")
+	}
+}
+
+func TestMigrationState(t *testing.T) {
+	project, err := ioutil.TempDir("", "seed-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(project)
+
+	migrations := []migration{
+		migration{Number: 1, Name: "1-maven.yml"},
+		migration{Number: 2, Name: "2-java.yml"},
+		migration{Number: 3, Name: "3-config.yml"},
+	}
+
+	state, err := readMigrationState(project)
+	if err != nil || len(state.Applied) != 0 || state.level(migrations) != "" {
+		t.Fatalf("A project without state should not have applied migrations but found %v, %v", state, err)
+	}
+
+	state.markApplied(migrations[0])
+	state.markApplied(migrations[1])
+	if err = writeMigrationState(project, state); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = readMigrationState(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := state.level(migrations); level != "2-java.yml" {
+		t.Errorf("The level should be 2-java.yml but found %s", level)
+	}
+	pending := pendingMigrations(migrations, state)
+	if len(pending) != 1 || pending[0].Name != "3-config.yml" {
+		t.Errorf("Only 3-config.yml should be pending but found %v", pending)
+	}
+}
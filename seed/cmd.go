@@ -11,16 +11,15 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/BurntSushi/toml"
-	"gopkg.in/yaml.v2"
+	"github.com/seedstack/tools/transform"
+	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 	"os"
-	"bufio"
 )
 
 const (
@@ -30,13 +29,41 @@ Fix the files in a given directory, based on a transformation file. If no direct
 is passed as argument, the transformations will be applied in current directory.
 
 Available flags:
- -t file/path.yml  the YAML transformation file
- -v                verbose mode
- -vv               very verbose mode
+ -t file/path.yml    the YAML transformation file
+ -var key=value      set a variable, can be repeated
+ -vars file/path.yml a YAML or TOML file defining variables
+ -only labels        only apply the transformations with one of the given
+                     comma-separated names or tags
+ -skip labels        skip the transformations with one of the given
+                     comma-separated names or tags
+ -git                refuse to fix a git work tree with uncommitted changes and
+                     commit the changes with a generated message
+ -branch name        create and check out a git branch before fixing, implies -git
+ -commit mode        commit once per "run" (default) or per "transformation",
+                     implies -git
+ -since ref          only transform the files which differ from the given git ref,
+                     like "master" or "HEAD~3", and the untracked files
+ -gitignore=false    don't skip the files ignored by git in a git work tree
+ -verify-idempotent  run the transformations twice in memory without writing
+                     and report the files still modified by the second run
+ -i                  show each change and ask whether to apply it
+ -o file/path.patch  write the changes as a git patch instead of modifying
+                     the files
+ -report file.json   write a JSON report of the transformations of each file
+ -stats              print the statistics and the time spent by each
+                     transformation
+ -j count            the number of files transformed concurrently, by default
+                     the number of CPUs
+ -timeout duration   the maximum duration of a plugin or a script run on a file,
+                     like "30s", by default 1m, 0 for no limit
+ -f                  force the rollback of the files edited after the run, or
+                     the replacement of an existing pre-commit hook
+ -v                  verbose mode
+ -vv                 very verbose mode
 
 Transformation file:
 
-The description file can be in YAML or TOML. It accepts a list of transformations.
+The description file can be in YAML, TOML or JSON. It accepts a list of transformations.
 Transformation contains filter based on the file name, but they can also use higher
 order precondition based on the file content. Finally, it takes a list of procedures
 to apply on the file. Procedures are described with a name and a list of arguments.
@@ -46,10 +73,19 @@ to exclude are separated by "|".
 Sample of YAML transformation file.
 
 ----------------
+include:
+  - "maven.yml"
+  - "https://example.com/tdf/java.yml"
 exclude: "target|.git"
+vars:
+  version: "15.4"
 transformations:
  -
+  name: "upgrade-myapp"
+  description: "Upgrade myApp1 to the current version"
+  tags: ["maven", "upgrade"]
   filter: "pom.xml"
+  once: true
   pre: 
     - AlwaysTrue
     - ...
@@ -60,229 +96,957 @@ transformations:
         - "old"
         - "new"
     - 
-      ...
+      name: ReplaceMavenDependency
+      params:
+        - "org.mycompany:myApp1:*"
+        - "org.mycompany:myApp1:${version}"
  -
   ...
 ----------------
 
-A convert method exists to convert yaml into toml see "seed convert [file] [format]".
+Includes:
+
+The "include" section lists other transformation files, local or HTTP(S), to
+compose with the current one. Relative paths are resolved from the including
+file. The transformations of the included files are applied first, in the order
+of the list, then the transformations of the including file. Their exclusions
+are merged and the variables of the including file override the included ones.
+Include cycles are rejected.
+
+Ignored files:
+
+In a git work tree, the files ignored by git are not transformed, based on the
+".gitignore" files and the ".git/info/exclude" file. The files listed in the
+".seedignore" files, which have the same syntax, are never transformed.
+
+Idempotency:
+
+Running a transformation file twice should not modify the files twice. Prefer
+the "EnsureInsert" procedure to "Insert", it only inserts a string which is not
+already present. A transformation with "once: true" is only applied once on each
+file, the files already transformed are recorded in ".seed/once.yml". Use the
+"-verify-idempotent" flag to find the files still modified by a second run.
+
+Variables:
+
+The "vars" section declares variables with their default values. They can be
+overridden by a variables file passed with "-vars" and then by the "-var" flags.
+Variables are referenced as "${name}" in filters, preconditions and procedure
+parameters. Use "$${name}" to keep a literal "${name}", like a Maven property.
+The transformations are not applied if a referenced variable is undefined.
+
+Migration chains:
+
+If "-t" is a directory, it is considered as a migration chain. Each transformation
+file of the directory is a migration whose name starts with a number, for instance
+"001-maven.yml" or "002-java.toml". The pending migrations are applied in order
+and recorded in the ".seed/migrations.yml" file of the fixed directory, so the next
+runs only apply the new migrations. See "seed help status".
+
+Plugins:
+
+The "Plugin" procedure runs an external executable on the file, for the
+transformations too specific to be built in seed. The executable receives on
+its standard input a JSON object with the "file" path, its "content" and the
+"params" following the command:
+
+  {"file": "src/App.java", "content": "...", "params": ["javax", "jakarta"]}
+
+It writes on its standard output a JSON object with the new "content", or an
+"error" failing the transformation of the file. The file is unchanged if the
+response has no content. The messages written on its standard error are shown
+in very verbose mode. Each worker runs one plugin at a time, so at most "-j"
+plugins run concurrently.
+
+Scripts:
+
+The "Script" procedure transforms the file with an inline Starlark script, a
+dialect of Python without access to the filesystem or the network. The script
+defines a "transform(file, content)" function returning the new content, or None
+to keep the file unchanged. The "Script(expression)" precondition evaluates a
+Starlark expression with the "file" and "content" variables:
+
+  pre:
+    - Script("javax." in content)
+  proc:
+    - name: Script
+      params:
+        - |
+          def transform(file, content):
+              return content.replace("javax.", "jakarta.")
+
+See "seed help Script".
+
+Commands:
+
+The "Command" procedure pipes the content of the file through an external
+command, typically a formatter, and replaces it with its standard output. The
+path of the file is in the SEED_FILE environment variable of the command.
+
+Tools which must run once on the whole project are declared in the "post" list
+of the transformation file. After the files are fixed, each post command runs
+once with the modified files matching its optional filter appended to its
+arguments. A command without matching files is not run:
+
+  post:
+    - filter: "*.java"
+      command: ["google-java-format", "--replace"]
+
+The post commands are not run by "seed check". With "-commit transformation"
+their changes are committed separately.
+
+Selecting transformations:
+
+A transformation can have a "name", unique in the transformation file and its
+includes, a "description" and a list of "tags". They are shown in the errors, the
+reports and the commit messages. "-only" applies only the transformations having
+one of the given comma-separated names or tags, and "-skip" doesn't apply the ones
+having one of them:
+
+  seed -t tdf.yml -only maven -skip upgrade-myapp fix
+
+An unknown name or tag is an error. The skipped transformations are not marked as
+applied by "once", and migration chains can't be filtered.
+
+Interactive mode:
+
+With "-i", each change is shown as diff hunks, with the transformation which
+produced it, and the user chooses to apply it (y), not to apply it (n), to apply
+it and all the next hunks of the transformation (a) or to quit (q), leaving the
+next changes unapplied. Only the accepted hunks are written. The files are fixed
+one at a time, and a transformation marked as "once" whose changes are all
+rejected is proposed again by the next run.
+
+Patches:
+
+With "-o changes.patch", the files are not modified: the changes of the run are
+written as a single git patch, with the paths relative to the top of the git work
+tree, to be reviewed and applied later with "git apply", possibly on another
+checkout. The files which don't exist yet, like the once markers of the project,
+are created by the patch. The post commands are not run, and "-o" can't be used
+with migration chains or with "-git", "-branch" and "-commit".
+
+Reports:
+
+With "-report report.json", the run writes a JSON report listing, for each file
+matching the filter of a transformation, the transformations applied or skipped,
+the result of each precondition, the procedures which modified the content, the
+changed bytes and lines, the error of the file and the time spent on it. The
+paths are relative to the fixed directory, so the reports of many projects can
+be aggregated. It also works with "seed check", without modifying any file.
+
+With "-stats", a table of the transformations is printed before the summary of
+the run: the files matched by the filter, the files passing the preconditions,
+the files changed, the replacements made and the time spent. It shows the
+transformations which never match and the slow procedures. The procedures which
+don't count their replacements, like the plugins and the scripts, count one
+replacement per file they change.
+
+Errors:
+
+A file on which a transformation fails, for instance because of an unknown
+procedure or invalid parameters, is left unmodified and the other files are still
+fixed. The errors are listed at the end of the run, with the failing transformation
+and procedure, and the command exits with a non-zero status.
+
+A transformation file is converted between YAML, TOML and JSON with "seed convert".
+`
+	checkHelp = `Usage: seed [flags] check [directory/to/check]
+
+Check the files of a directory against a transformation file without modifying
+them. The files which would be modified by "seed fix" are listed and the command
+exits with a non-zero status if there are any, so it can be used in a CI build or
+in a pre-commit hook. It accepts the same flags as "seed fix", use "-since" to
+only check the files modified in the current branch:
+
+        seed -t tdf.yml -since origin/master check
+
+To show the files to fix in code review tools and CI test tabs, "-sarif" writes
+a SARIF log with a rule per transformation and a result per file to fix, located
+at the lines the transformation would change. "-junit" writes a JUnit XML report
+with a test case per transformation, failing if it would change files:
+
+        seed -t tdf.yml -sarif seed.sarif -junit seed.xml check
+`
+	statusHelp = `Usage: seed -t migrations/directory status [directory/to/check]
+
+Show the migration level of a directory, i.e. the last applied migration of the
+chain, and list its pending migrations. If no directory is passed as argument, the
+current directory is checked.
+`
+	rollbackHelp = `Usage: seed [-f] rollback [run-id|last] [directory/to/restore]
+
+Restore the files modified by a run of "seed fix", by default the last one. Each
+run journals the original content of the files it modifies in the ".seed/runs"
+directory of the fixed directory, the run identifier is printed at the end of the
+run. The files edited again after the run are not restored unless the "-f" flag
+is passed.
+`
+	hookHelp = `Usage: seed [flags] hook install|run [directory]
+
+Use seed as a git pre-commit hook. The "install" command writes a pre-commit
+hook in the git repository of the directory, which runs "seed hook run" with the
+transformation file and the variables passed to the install command:
+
+        seed -t tdf.yml -restage hook install
+
+The "run" command applies the transformations on the staged content of the
+staged files, not on the work tree, except the files ignored like by "seed fix".
+Without "-restage", the commit is refused and the fixes are printed as a diff.
+With "-restage", the fixed content is staged, and also written in the work tree
+if the file has no unstaged changes, and the "once" transformations are recorded.
+An existing pre-commit hook is not replaced unless the "-f" flag is passed.
+`
+	validateHelp = `Usage: seed [-t file/path.yml] validate
+
+Check a transformation file, or all the migrations of a chain directory, without
+transforming any file. The filters and exclusions must be valid patterns, the
+preconditions and procedures must exist and the procedures must have the expected
+number of parameters, for instance pairs of strings for "Replace". All the problems
+are listed with their file and transformation, and their line in the YAML files.
+The command exits with a non-zero status if there are any. The same checks are
+run before "seed fix" modifies any file.
+`
+	convertHelp = `Usage: seed convert file/path.yml [yml|toml|json]
+
+Convert a transformation file between the YAML, TOML and JSON formats. The format
+of the converted file is given as argument or by the extension of the "-o" path.
+The converted file is written to the "-o" path, or to the standard output, and
+never overwrites the original file. The converted file has the same
+transformations, its includes are kept but they are not converted:
+
+        seed -o tdf.toml convert tdf.yml
+        seed convert tdf.toml json > tdf.json
+`
+	versionHelp = `Usage: seed version
+
+Show the version of the seed tool.
+`
+	helpHelp = `Usage: seed help [command|procs|pre|name]
+
+Show the help of a command. "seed help procs" lists the procedures which can be used
+in the "proc" section of a transformation, with their parameters and examples, and
+"seed help pre" lists the preconditions of the "pre" section. Pass the name of a
+procedure or a precondition to only show its help, for instance "seed help Replace".
 `
 	seedHelp = `Usage: seed <command> <args>
 
 Commands:
     fix      Apply source transformations on a directory
-    convert  Convert a yaml transformation file into toml
+    check    List the files which need to be fixed
+    status   Show the migration level of a directory
+    rollback Restore the files modified by a fix
+    hook     Install or run seed as a git pre-commit hook
+    validate Check a transformation file without applying it
+    convert  Convert a transformation file between yaml, toml and json
     help     Provide help for seed commands 
     version  Show the seed tool version
 
-See 'seed help <command>' to read about a specific subcommand.
+See 'seed help <command>' to read about a specific subcommand, 'seed help procs'
+and 'seed help pre' to list the procedures and preconditions of the transformations.
 `
 )
 
-// T correspond to the content of a transformation file.
-// It contains exclude directories and an array of transformations.
-type T struct {
-	Exclude         string
-	Transformations []Transformation
-}
-
-// Transformation is a strutucture representating a set
-// of procedure to apply on a source code directory
-type Transformation struct {
-	Filter string
-	Pre    []string
-	Proc   []Procedure
-}
-
-// Procedure is a function call with a method name and
-// its parameters
-type Procedure struct {
-	Name   string
-	Params []string
-}
-
 var transPath string
 var verbose bool
 var vverbose bool
 var dirPath = "./"
+var varsPath string
+var cliVars = make(varFlags)
+var verifyIdempotent bool
+var force bool
+var useGit bool
+var gitBranch string
+var commitMode string
+var gitWorkTree *gitRepo
+var gitIgnore bool
+var sinceRef string
+var checkOnly bool
+var restage bool
+var jobs int
+var timeout time.Duration
+var outputPath string
+var onlyLabels string
+var skipLabels string
+
+// runErrors collects the errors of the files which failed during the run
+var runErrors []error
+
+// onceApplied records the files already transformed by the transformations
+// marked as "once" in the directory to fix.
+var onceApplied *transform.OnceState
 
 func init() {
 	flag.StringVar(&transPath, "t", "./tdf.yml", "Specify the path to the transformation description file")
 	flag.BoolVar(&verbose, "v", false, "Enable verbose mode.")
 	flag.BoolVar(&vverbose, "vv", false, "Enable very verbose mode.")
+	flag.IntVar(&jobs, "j", runtime.NumCPU(), "Specify the number of files transformed concurrently.")
+	flag.DurationVar(&timeout, "timeout", time.Minute, "Specify the maximum duration of a plugin or a script run on a file.")
+	flag.StringVar(&reportPath, "report", "", "Write a JSON report of the transformations of each file to the given path.")
+	flag.StringVar(&sarifPath, "sarif", "", "Write the files to fix by \"seed check\" as a SARIF log to the given path.")
+	flag.StringVar(&junitPath, "junit", "", "Write the result of \"seed check\" as a JUnit XML report to the given path.")
+	flag.StringVar(&outputPath, "o", "", "Write the changes of \"seed fix\" as a git patch, or the file converted by \"seed convert\", to the given path.")
+	flag.BoolVar(&interactive, "i", false, "Show each change of \"seed fix\" and ask whether to apply it.")
+	flag.BoolVar(&showStats, "stats", false, "Print the statistics and the time spent by each transformation.")
+	flag.StringVar(&onlyLabels, "only", "", "Only apply the transformations with one of the given comma-separated names or tags.")
+	flag.StringVar(&skipLabels, "skip", "", "Skip the transformations with one of the given comma-separated names or tags.")
+	flag.StringVar(&varsPath, "vars", "", "Specify the path to a file defining variables")
+	flag.Var(cliVars, "var", "Set a variable as key=value, can be repeated")
+	flag.BoolVar(&force, "f", false, "Force the rollback of the files edited after the run or the replacement of a git hook.")
+	flag.BoolVar(&useGit, "git", false, "Refuse to fix a git work tree with uncommitted changes and commit the changes.")
+	flag.StringVar(&gitBranch, "branch", "", "Create the given git branch before fixing, implies -git.")
+	flag.StringVar(&commitMode, "commit", "", `Commit once per "run" (default) or per "transformation", implies -git.`)
+	flag.StringVar(&sinceRef, "since", "", "Only transform the files which differ from the given git ref and the untracked files.")
+	flag.BoolVar(&gitIgnore, "gitignore", true, "Skip the files ignored by git in a git work tree.")
+	flag.BoolVar(&restage, "restage", false, "Stage the fixed files in the pre-commit hook instead of refusing the commit.")
+	flag.BoolVar(&verifyIdempotent, "verify-idempotent", false, "Run the transformations twice without writing and report the files modified twice.")
+}
+
+func main() {
 	flag.Parse()
 
 	if vverbose {
 		verbose = true
 	}
-}
 
-func main() {
 	switch flag.Arg(0) {
 	case "fix":
 		fix()
+	case "check":
+		checkOnly = true
+		fix()
+	case "status":
+		status()
+	case "rollback":
+		rollback()
+	case "hook":
+		hook()
+	case "validate":
+		validate()
 	case "convert":
 		convertTdf(flag.Arg(1), flag.Arg(2))
 	case "help":
-		if flag.Arg(1) == "fix" {
-			fmt.Println(fixHelp)
+		if !printHelp(os.Stdout, flag.Arg(1)) {
+			fmt.Printf("Unknown help topic \"%s\".\n\n", flag.Arg(1))
+			printHelp(os.Stdout, "")
+			os.Exit(1)
 		}
 	case "version":
 		fmt.Println("Seed Tool v0.1")
 	default:
-		fmt.Println(seedHelp)
+		printHelp(os.Stdout, "")
 	}
 }
 
 func fix() {
 	start := time.Now()
+	setDirPath(flag.Arg(1))
+	if (sarifPath != "" || junitPath != "") && !checkOnly {
+		log.Fatal("The SARIF and JUnit reports are only written by the check command.")
+	}
+	if outputPath != "" {
+		startPatch()
+	}
+	if interactive {
+		if checkOnly || verifyIdempotent {
+			log.Fatal("The interactive mode is only supported by the fix command.")
+		}
+		runReviewer = newReviewer(os.Stdin, os.Stdout)
+	}
+	startReport()
 
-	var dat []byte
-	var tdfPath string
+	if info, err := os.Stat(transPath); err == nil && info.IsDir() {
+		if verifyIdempotent {
+			log.Fatal("The idempotency verification doesn't support migration chains.")
+		}
+		if checkOnly {
+			log.Fatal("The check command doesn't support migration chains, use the status command.")
+		}
+		if onlyLabels != "" || skipLabels != "" {
+			log.Fatal("The migrations of a chain are applied entirely, don't use -only or -skip.")
+		}
+		fixChain(start)
+		return
+	}
 
-	if strings.HasPrefix(transPath, "http://") || strings.HasPrefix(transPath, "https://") {
-		dat = fetchURL(transPath)
-	} else {
-		dat = readFile(transPath)
+	// Validate the transformations before modifying the work tree
+	preflight(transPath)
+	if !verifyIdempotent && !checkOnly && runPatch == nil {
+		prepareGit()
+		startJournal()
+	}
+	transf, count, total := applyTdf(transPath)
+	saveReport(start, count, total, transf.Transformations)
+	if showStats {
+		fmt.Println()
+		printStats(os.Stdout, transf.Transformations, runReport.Files())
 	}
 
-	if verbose {
-		fmt.Printf("Apply transformations from: %s.\n\n---\n", transPath)
+	elapsed := time.Since(start)
+	if checkOnly {
+		fmt.Printf("\n%s has %v/%v files to fix in %s\n", shortDirPath(), count, total, elapsed)
+		exitOnErrors()
+		if count > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if verifyIdempotent {
+		fmt.Printf("\n%s has %v/%v files not idempotent in %s\n", shortDirPath(), count, total, elapsed)
+		exitOnErrors()
+		if count > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Printf("\n%s fixed %v/%v files in %s\n", shortDirPath(), count, total, elapsed)
+	savePatch()
+	if len(runErrors) == 0 {
+		commitRun(fmt.Sprintf("Apply %s", filepath.Base(transPath)), transf.Transformations)
+	}
+	printRollbackHint()
+	exitOnErrors()
+}
+
+// exitOnErrors prints the errors of the run, if any, and exits with a
+// non-zero status.
+func exitOnErrors() {
+	if len(runErrors) == 0 {
+		return
+	}
+	printErrors(os.Stderr, runErrors)
+	if gitWorkTree != nil && !checkOnly && !verifyIdempotent {
+		fmt.Fprintln(os.Stderr, "The changes were not committed because of the errors.")
 	}
+	os.Exit(1)
+}
 
-	format, err := getFormat(transPath)
+// printErrors prints a summary of the errors of a run.
+func printErrors(w io.Writer, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%v errors:\n", len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(w, "\t%s\n", err)
+	}
+}
+
+// fixChain applies the pending migrations of the chain directory in order.
+// The state of the project is saved after each migration.
+func fixChain(start time.Time) {
+	migrations, err := listMigrations(transPath)
+	if err != nil {
+		log.Fatalf("Failed to list the migrations of %s: %s", transPath, err)
+	}
+	state, err := readMigrationState(dirPath)
 	if err != nil {
-		log.Fatalf("Unsupported format for %s", transPath)
+		log.Fatalf("Failed to read the migration state of %s: %s", dirPath, err)
 	}
-	transf := parseTdf(dat, format)
 
-	// set the directory to parse if specified
-	if flag.Arg(1) != "" {
-		absPath, errFilePath := filepath.Abs(flag.Arg(1))
-		if errFilePath != nil {
-			log.Fatal("Error constructing the file path.\n", errFilePath)
-		}
-		dirPath = absPath
+	pending := pendingMigrations(migrations, state)
+	// Validate all the migrations before applying the first one
+	for _, m := range pending {
+		preflight(m.Path)
 	}
+	prepareGit()
+	startJournal()
+
+	var names []string
+	var applied []transform.Transformation
+	var fixed, scanned int
+	for _, m := range pending {
+		transf, count, total := applyTdf(m.Path)
+		names = append(names, m.Name)
+		applied = append(applied, transf.Transformations...)
+		fixed, scanned = fixed+count, scanned+total
+		fmt.Printf("%s: fixed %v/%v files\n", m.Name, count, total)
+		if showStats {
+			printStats(os.Stdout, transf.Transformations, runReport.Files())
+		}
+		if len(runErrors) > 0 {
+			saveReport(start, fixed, scanned, applied)
+			// The next migrations may depend on the failed one
+			fmt.Printf("%s failed, the next migrations are not applied\n", m.Name)
+			printRollbackHint()
+			exitOnErrors()
+		}
 
-	files := walkDir(dirPath, transf.Exclude, tdfPath)
-	count := processFiles(files, transf)
+		state.markApplied(m)
+		if err = writeMigrationState(dirPath, state); err != nil {
+			log.Fatalf("Failed to save the migration state of %s: %s", dirPath, err)
+		}
+		saveJournal()
+	}
 
+	saveReport(start, fixed, scanned, applied)
 	elapsed := time.Since(start)
-	var shortDirPath = filepath.Base(dirPath)
-	if shortDirPath == "." {
-		wd, err := os.Getwd()
+	fmt.Printf("\n%s applied %v migrations in %s, current level: %s\n",
+		shortDirPath(), len(pending), elapsed, levelName(state.level(migrations)))
+	if len(pending) > 0 {
+		commitRun(fmt.Sprintf("Apply migrations %s", strings.Join(names, ", ")), applied)
+	}
+	printRollbackHint()
+}
+
+// prepareGit checks that the git work tree of the directory to fix doesn't
+// have uncommitted changes and creates the requested branch. It does nothing
+// if the git integration is not enabled.
+func prepareGit() {
+	if !useGit && gitBranch == "" && commitMode == "" {
+		return
+	}
+	if commitMode != "" && commitMode != "run" && commitMode != "transformation" {
+		log.Fatalf(`Unsupported commit mode "%s", expected "run" or "transformation"`, commitMode)
+	}
+
+	repo, err := openGitRepo(dirPath)
+	if err != nil {
+		log.Fatalf("%s is not in a git work tree: %s", dirPath, err)
+	}
+	changes, err := repo.changes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(changes) > 0 {
+		log.Fatalf("%s has uncommitted changes, commit or stash them first:\n%s", repo.Root, strings.Join(changes, "\n"))
+	}
+	if gitBranch != "" {
+		if err = repo.createBranch(gitBranch); err != nil {
+			log.Fatal(err)
+		}
+	}
+	gitWorkTree = &repo
+}
+
+// commitRun commits all the changes of the run, unless each transformation
+// is committed separately.
+func commitRun(subject string, transformations []transform.Transformation) {
+	if gitWorkTree == nil || commitMode == "transformation" {
+		return
+	}
+	changed, err := gitWorkTree.commit(commitMessage(subject, transformations))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(changed) > 0 {
+		fmt.Printf("Committed %v changes: %s\n", len(changed), subject)
+	}
+}
+
+// commitTransformations applies the transformations one by one and commits
+// the changes of each one. It returns the number of fixed files and stops
+// at the first transformation failing on a file.
+func commitTransformations(files []string, t transform.T, path string) (int, []error) {
+	fixed := make(map[string]bool)
+	var modified []string
+	commit := func(subject, message string) {
+		changed, err := gitWorkTree.commit(message)
 		if err != nil {
-			log.Fatalf("Failed to get current dir: %s", err)
+			log.Fatal(err)
+		}
+		for _, line := range changed {
+			file := line[strings.LastIndex(line, "\t")+1:]
+			if !strings.HasPrefix(file, stateDir+"/") && !strings.Contains(file, "/"+stateDir+"/") {
+				fixed[file] = true
+			}
+		}
+		if len(changed) > 0 {
+			fmt.Printf("Committed %v changes: %s\n", len(changed), subject)
 		}
-		shortDirPath = filepath.Base(wd)
 	}
-	fmt.Printf("\n%s fixed %v/%v files in %s\n", shortDirPath, count, len(files), elapsed)
+
+	for i, transf := range t.Transformations {
+		if !options().Selects(transf) {
+			continue
+		}
+		opts := options()
+		opts.Index = i + 1
+		res := transform.Fix(files, t, opts)
+		if len(res.Errors) > 0 {
+			return len(fixed), res.Errors
+		}
+		modified = append(modified, res.Files...)
+		if err := onceApplied.Save(writeFile); err != nil {
+			log.Fatalf("Failed to save the once markers of %s: %s", dirPath, err)
+		}
+
+		name := fmt.Sprintf("%v", i+1)
+		if transf.Name != "" {
+			name = transf.Name
+		}
+		subject := fmt.Sprintf("Apply transformation %s of %s", name, filepath.Base(path))
+		commit(subject, commitMessage(subject, []transform.Transformation{transf}))
+	}
+
+	if errs := runPost(t, modified); len(errs) > 0 {
+		return len(fixed), errs
+	}
+	subject := fmt.Sprintf("Run the post commands of %s", filepath.Base(path))
+	commit(subject, postCommitMessage(subject, t.Post))
+	return len(fixed), nil
 }
 
-func getFormat(name string) (string, error) {
-	index := strings.LastIndex(name, ".") + 1
-	extension := strings.ToLower(name[index:])
+// runPost runs the post commands of the transformation file on the fixed
+// files. The journal of the run keeps the content they produced, so the
+// files are not seen as edited after the run by a rollback.
+func runPost(t transform.T, files []string) []error {
+	errs := transform.RunPost(t, files, options())
+	if runJournal == nil {
+		return errs
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err == nil {
+			err = runJournal.record(f, data)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
 
-	var ext string
-	var err error
+// startJournal starts journaling the files modified by the run.
+func startJournal() {
+	j, err := newJournal(dirPath)
+	if err != nil {
+		log.Fatalf("Failed to start the journal of the run: %s", err)
+	}
+	runJournal = j
+}
 
-	switch extension {
-	case "yml", "yaml":
-		ext = "yml"
-	case "toml":
-		ext = "toml"
-	default:
-		err = fmt.Errorf("%s format unsupported", extension)
+// saveJournal saves the journal of the run, so the files already
+// modified can be restored even if the run is interrupted later.
+func saveJournal() {
+	if runJournal == nil {
+		return
 	}
+	if err := runJournal.save(); err != nil {
+		log.Fatalf("Failed to save the journal of the run: %s", err)
+	}
+}
 
-	return ext, err
+func printRollbackHint() {
+	if runJournal != nil && len(runJournal.Files) > 0 {
+		fmt.Printf("Use \"seed rollback %s\" to restore the original files.\n", runJournal.ID)
+	}
 }
 
-func fetchURL(url string) []byte {
-	resp, err := http.Get(transPath)
+// rollback restores the files modified by a run, by default the last one.
+func rollback() {
+	setDirPath(flag.Arg(2))
+
+	id := flag.Arg(1)
+	if id == "" || id == "last" {
+		runs, err := listRuns(dirPath)
+		if err != nil {
+			log.Fatalf("Failed to list the runs of %s: %s", dirPath, err)
+		}
+		if len(runs) == 0 {
+			fmt.Printf("%s has no run to roll back\n", shortDirPath())
+			return
+		}
+		id = runs[len(runs)-1]
+	}
+
+	j, err := readJournal(dirPath, id)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Failed to read the journal of the run %s: %s", id, err)
+	}
+	total := len(j.Files)
+	edited, err := j.rollback(force)
+	if err != nil {
+		log.Fatalf("Failed to roll back the run %s: %s", id, err)
+	}
+
+	fmt.Printf("%s restored %v/%v files of the run %s\n", shortDirPath(), total-len(edited), total, id)
+	if len(edited) > 0 {
+		fmt.Println("The following files were edited after the run, use -f to restore them anyway:")
+		for _, path := range edited {
+			fmt.Printf("\t%s\n", path)
+		}
+		os.Exit(1)
+	}
+}
+
+// status reports the migration level of the project and its pending
+// migrations.
+func status() {
+	setDirPath(flag.Arg(1))
+
+	migrations, err := listMigrations(transPath)
+	if err != nil {
+		log.Fatalf("Failed to list the migrations of %s: %s", transPath, err)
+	}
+	state, err := readMigrationState(dirPath)
+	if err != nil {
+		log.Fatalf("Failed to read the migration state of %s: %s", dirPath, err)
+	}
+
+	fmt.Printf("%s current level: %s\n", shortDirPath(), levelName(state.level(migrations)))
+	pending := pendingMigrations(migrations, state)
+	if len(pending) == 0 {
+		fmt.Println("No pending migration.")
+		return
 	}
-	if resp.StatusCode > 299 {
-		log.Fatalf("Error %v when fetching %s\n", resp.StatusCode, transPath)
+	fmt.Println("Pending migrations:")
+	for _, m := range pending {
+		fmt.Printf("\t%s\n", m.Name)
 	}
+}
+
+func levelName(level string) string {
+	if level == "" {
+		return "none"
+	}
+	return level
+}
 
-	body, err2 := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
+// applyTdf applies the transformation file on the directory to fix.
+// It returns the applied transformations, the number of fixed files and
+// the number of checked files.
+// When checking or verifying the idempotency, nothing is written and the
+// number of files to fix or not idempotent is returned instead.
+func applyTdf(path string) (transform.T, int, int) {
+	if verbose {
+		fmt.Printf("Apply transformations from: %s.\n\n---\n", path)
+	}
+
+	transf := loadTransformations(path)
+	startReview(transf.Transformations)
+	state, err := transform.ReadOnceState(dirPath)
 	if err != nil {
-		log.Fatal("Error reading http reponse.\n", err2)
+		log.Fatalf("Failed to read the once markers of %s: %s", dirPath, err)
+	}
+
+	files, errs := transform.Walk(dirPath, transf.Exclude, path, options())
+	runErrors = append(runErrors, errs...)
+	if sinceRef != "" {
+		files = filterSince(files, sinceRef)
+	}
+	if checkOnly {
+		onceApplied = state.Copy()
+		res := transform.Check(files, transf, options())
+		runErrors = append(runErrors, res.Errors...)
+		for _, f := range res.Files {
+			fmt.Printf("%s should be fixed\n", transform.ShortPath(f))
+		}
+		return transf, len(res.Files), len(files)
+	}
+	if verifyIdempotent {
+		onceApplied = state.Copy()
+		res := transform.Verify(files, transf, options())
+		runErrors = append(runErrors, res.Errors...)
+		for _, f := range res.Files {
+			fmt.Printf("%s is still modified by a second run\n", transform.ShortPath(f))
+		}
+		return transf, len(res.Files), len(files)
 	}
 
-	return body
+	onceApplied = state
+	if runJournal != nil {
+		runJournal.tdf = path
+	}
+	var count int
+	if gitWorkTree != nil && commitMode == "transformation" {
+		count, errs = commitTransformations(files, transf, path)
+	} else {
+		res := transform.Fix(files, transf, options())
+		count, errs = len(res.Files), res.Errors
+		if len(errs) == 0 && runPatch != nil && len(transf.Post) > 0 {
+			fmt.Println("The post commands are not run when the changes are written to a patch.")
+		} else if len(errs) == 0 {
+			errs = runPost(transf, res.Files)
+		}
+	}
+	runErrors = append(runErrors, errs...)
+	if err = state.Save(writeFile); err != nil {
+		log.Fatalf("Failed to save the once markers of %s: %s", dirPath, err)
+	}
+	saveJournal()
+	return transf, count, len(files)
 }
 
-func readFile(path string) []byte {
-	absPath, errFilePath := filepath.Abs(path)
-	tdfPath := absPath
+// options returns the options of the engine set by the flags.
+func options() transform.Options {
+	opts := transform.Options{
+		Jobs:        jobs,
+		Verbose:     verbose,
+		VeryVerbose: vverbose,
+		Out:         os.Stdout,
+		GitIgnore:   gitIgnore,
+		Once:        onceApplied,
+		WriteFile:   writeFile,
+		Timeout:     timeout,
+		Report:      runReport,
+		Only:        labels(onlyLabels),
+		Skip:        labels(skipLabels),
+	}
+	if runReviewer != nil {
+		opts.Review = runReviewer.review
+	}
+	return opts
+}
 
-	if errFilePath != nil {
-		log.Fatal("Error constructing the file path.\n", errFilePath)
+// loadTransformations loads the transformation file, with its includes,
+// and resolves its variables.
+func loadTransformations(path string) transform.T {
+	transf, err := transform.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %s", path, err)
 	}
 
-	bytes, err := ioutil.ReadFile(tdfPath)
+	var fileVars map[string]string
+	if varsPath != "" {
+		if fileVars, err = transform.ReadVars(varsPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	transf, err = transform.Interpolate(transf, transform.MergeVars(transf.Vars, fileVars, cliVars))
 	if err != nil {
-		log.Fatal("Unable to read the transformation description file.\n", err)
+		log.Fatalf("Failed to resolve the variables of %s: %s", path, err)
 	}
-	return bytes
+	return transf
 }
 
-func parseTdf(dat []byte, format string) T {
-	var t T
+// preflight loads the transformation file and validates it. The run is
+// stopped with the list of the problems before any file is transformed.
+func preflight(path string) transform.T {
+	transf := loadTransformations(path)
+	if errs := transform.Validate(transf, path); len(errs) > 0 {
+		printErrors(os.Stderr, errs)
+		os.Exit(1)
+	}
+	if unknown := transform.UnknownSelections(transf, options()); len(unknown) > 0 {
+		log.Fatalf("No transformation of %s is named or tagged %s.", path, strings.Join(unknown, ", "))
+	}
+	return transf
+}
 
-	switch format {
-	case "yml":
-		err := yaml.Unmarshal(dat, &t)
-		if err != nil {
-			log.Fatalf("Failed to parse the yaml file: %s", err)
+// labels splits the comma-separated names and tags of -only or -skip.
+func labels(list string) []string {
+	var res []string
+	for _, label := range strings.Split(list, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			res = append(res, label)
 		}
-	case "toml":
-		err := toml.Unmarshal(dat, &t)
+	}
+	return res
+}
+
+// validate checks the transformation file, or the migrations of a chain,
+// without applying them.
+func validate() {
+	paths := []string{transPath}
+	if info, err := os.Stat(transPath); err == nil && info.IsDir() {
+		migrations, err := listMigrations(transPath)
 		if err != nil {
-			log.Fatalf("Failed to parse the toml file: %s", err)
+			log.Fatalf("Failed to list the migrations of %s: %s", transPath, err)
+		}
+		paths = nil
+		for _, m := range migrations {
+			paths = append(paths, m.Path)
 		}
 	}
-	return t
+
+	var errs []error
+	for _, path := range paths {
+		errs = append(errs, transform.Validate(loadTransformations(path), path)...)
+	}
+	if len(errs) > 0 {
+		printErrors(os.Stdout, errs)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", transPath)
 }
 
-func convertTdf(path, newFormat string) {
-	index := strings.LastIndex(path, ".") + 1
-	format, err := getFormat(path[index:])
+// hook installs or runs the pre-commit hook.
+func hook() {
+	setDirPath(flag.Arg(2))
+
+	repo, err := openGitRepo(dirPath)
 	if err != nil {
-		log.Fatalf("Unsupported format for %s", path)
+		log.Fatalf("%s is not in a git work tree: %s", dirPath, err)
 	}
-	
-	bytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		log.Fatal("Unable to read the transformation description file.\n", err)
+	if info, err := os.Stat(transPath); err == nil && info.IsDir() {
+		log.Fatal("The pre-commit hook doesn't support migration chains.")
 	}
-	var t T
-	var res []byte
 
-	f, err := os.Create(path[:index] + "toml")
+	switch flag.Arg(1) {
+	case "install":
+		args, err := hookArgs(transPath, varsPath, cliVars, restage)
+		if err != nil {
+			log.Fatal(err)
+		}
+		path, err := installHook(repo, args, force)
+		if err != nil {
+			log.Fatalf("Failed to install the pre-commit hook: %s", err)
+		}
+		fmt.Printf("Installed the pre-commit hook %s\n", path)
+	case "run":
+		transf := preflight(transPath)
+		state, err := transform.ReadOnceState(dirPath)
+		if err != nil {
+			log.Fatalf("Failed to read the once markers of %s: %s", dirPath, err)
+		}
+		onceApplied = state.Copy()
+
+		fixes, err := fixStaged(repo, transf, transPath, restage, options())
+		if err != nil {
+			log.Fatalf("Failed to fix the staged files: %s", err)
+		}
+		if restage {
+			for _, f := range fixes {
+				fmt.Printf("%s was fixed and staged again\n", f.Path)
+			}
+			return
+		}
+		for _, f := range fixes {
+			fmt.Print(f.Diff)
+		}
+		if len(fixes) > 0 {
+			fmt.Printf("\n%v staged files should be fixed, apply the diff above and stage them again\n", len(fixes))
+			os.Exit(1)
+		}
+	default:
+		printHelp(os.Stdout, "hook")
+	}
+}
+
+// filterSince keeps the files which differ from the given git ref
+// and the untracked files.
+func filterSince(files []string, ref string) []string {
+	repo, err := openGitRepo(dirPath)
+	if err != nil {
+		log.Fatalf("%s is not in a git work tree: %s", dirPath, err)
+	}
+	changed, err := repo.changedSince(ref)
 	if err != nil {
 		log.Fatal(err)
 	}
-	
-	writer := bufio.NewWriter(f)
-	
-	if newFormat == "toml" && (format == "yaml" || format == "yml") {
-		err := yaml.Unmarshal(bytes, &t)
-		if err != nil {
-			log.Fatalf("Failed to parse the yaml file: %s", err)
+	return filterChanged(files, changed)
+}
+
+// setDirPath sets the directory to parse if specified.
+func setDirPath(dir string) {
+	if dir != "" {
+		absPath, errFilePath := filepath.Abs(dir)
+		if errFilePath != nil {
+			log.Fatal("Error constructing the file path.\n", errFilePath)
 		}
+		dirPath = absPath
+	}
+}
 
-		if err = toml.NewEncoder(writer).Encode(t); err != nil {
-			log.Fatal(err)
+func shortDirPath() string {
+	var shortDirPath = filepath.Base(dirPath)
+	if shortDirPath == "." {
+		wd, err := os.Getwd()
+		if err != nil {
+			log.Fatalf("Failed to get current dir: %s", err)
 		}
-	} else {
-		log.Fatalf("%s format is not supported for convertion", format)
+		shortDirPath = filepath.Base(wd)
 	}
-	
-	ioutil.WriteFile(path[:index], res, 0666)
+	return shortDirPath
 }
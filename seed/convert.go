@@ -0,0 +1,91 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// convertTdf writes the transformation file converted to the given format
+// to the -o path, or to the standard output.
+func convertTdf(path, newFormat string) {
+	if path == "" {
+		fmt.Print(convertHelp)
+		os.Exit(1)
+	}
+	dat, err := convert(path, newFormat, outputPath)
+	if err != nil {
+		log.Fatalf("Failed to convert %s: %s", path, err)
+	}
+	if outputPath == "" {
+		os.Stdout.Write(dat)
+		return
+	}
+	if err = ioutil.WriteFile(outputPath, dat, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %s", outputPath, err)
+	}
+	fmt.Printf("Converted %s to %s\n", path, outputPath)
+}
+
+// convert returns the content of the transformation file in the format,
+// or in the format of the output path if the format is empty. The
+// includes are kept as is, they are not converted.
+func convert(path, format, output string) ([]byte, error) {
+	from, err := transform.Format(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = output
+	}
+	if format == "" {
+		return nil, errors.New("the format of the converted file is missing")
+	}
+	to, err := transform.Format(format)
+	if err != nil {
+		return nil, err
+	}
+	if output != "" {
+		if outFormat, _ := transform.Format(output); outFormat != to {
+			return nil, fmt.Errorf("the extension of %s doesn't match the %s format", output, format)
+		}
+		if sameFile(path, output) {
+			return nil, fmt.Errorf("%s would be overwritten", path)
+		}
+	}
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := transform.Parse(dat, from)
+	if err != nil {
+		return nil, err
+	}
+	return transform.Encode(t, to)
+}
+
+// sameFile returns true if both paths designate the same file, even through
+// a link.
+func sameFile(path, other string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absOther, err2 := filepath.Abs(other)
+	if err1 == nil && err2 == nil && absPath == absOther {
+		return true
+	}
+	info, err1 := os.Stat(path)
+	otherInfo, err2 := os.Stat(other)
+	return err1 == nil && err2 == nil && os.SameFile(info, otherInfo)
+}
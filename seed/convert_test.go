@@ -0,0 +1,75 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seed-convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tdf.yml")
+	tdf := "transformations:\n  - filter: \"*.txt\"\n    proc: [{name: Insert, params: [\"!\"]}]\n"
+	if err = ioutil.WriteFile(path, []byte(tdf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := transform.Parse([]byte(tdf), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		format, output, parsed string
+		keys                   []string
+	}{
+		{"toml", "", "toml", []string{"[[transformations]]", "filter = ", "[[transformations.proc]]", "name = ", "params = "}},
+		{"", filepath.Join(dir, "tdf.json"), "json", []string{`"transformations":`, `"filter":`, `"proc":`, `"name":`, `"params":`}},
+		{"yaml", filepath.Join(dir, "copy.yml"), "yml", []string{"transformations:", "filter:", "proc:", "name:", "params:"}},
+	} {
+		dat, err := convert(path, c.format, c.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range c.keys {
+			if !strings.Contains(string(dat), key) {
+				t.Errorf("The conversion to %s should write the %s key but found\n%s", c.parsed, key, dat)
+			}
+		}
+		tr, err := transform.Parse(dat, c.parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tr, expected) {
+			t.Errorf("The conversion to %s should keep the transformations but found %+v", c.parsed, tr)
+		}
+	}
+
+	for _, c := range []struct{ format, output string }{
+		{"", ""},
+		{"xml", ""},
+		{"toml", filepath.Join(dir, "tdf.json")},
+		{"", path},
+		{"yml", filepath.Join(dir, ".", "tdf.yml")},
+	} {
+		if _, err := convert(path, c.format, c.output); err == nil {
+			t.Errorf("The conversion to %q in %q should fail", c.format, c.output)
+		}
+	}
+}
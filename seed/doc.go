@@ -12,7 +12,7 @@ Perform common tasks on SEED projects.
 
 Install
 
-        go get github.com/seedstack/tools/seed
+        go install github.com/seedstack/tools/seed@latest
 
 The following assumes you have Go properly installed.
 
@@ -0,0 +1,197 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepo is a git work tree on which the git commands are run.
+type gitRepo struct {
+	// Dir is the directory where the commands are run
+	Dir string
+	// Root is the top level directory of the work tree
+	Root string
+}
+
+// git runs a git command in the given directory and returns its output.
+func git(dir string, args ...string) (string, error) {
+	out, err := gitWithInput(dir, nil, args...)
+	return string(out), err
+}
+
+// gitWithInput runs a git command in the given directory with the input
+// on its standard input and returns its raw output.
+func gitWithInput(dir string, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %s %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// openGitRepo returns the git work tree containing dir. It fails if
+// git is not installed or if dir is not in a work tree.
+func openGitRepo(dir string) (gitRepo, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return gitRepo{}, err
+	}
+	return gitRepo{Dir: dir, Root: filepath.FromSlash(strings.TrimSpace(out))}, nil
+}
+
+func (r gitRepo) git(args ...string) (string, error) {
+	return git(r.Dir, args...)
+}
+
+// changes returns the uncommitted changes of the work tree, including
+// the untracked files, in the git porcelain format.
+func (r gitRepo) changes() ([]string, error) {
+	out, err := r.git("status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	return nonEmptyLines(out), nil
+}
+
+// createBranch creates the branch from the current commit and checks it out.
+func (r gitRepo) createBranch(name string) error {
+	_, err := r.git("checkout", "-b", name)
+	return err
+}
+
+// commit stages all the changes of the directory and commits them with the
+// given message. A list of the changed files is appended to the message.
+// It returns the changed files, which are not committed if there is none.
+func (r gitRepo) commit(message string) ([]string, error) {
+	if _, err := r.git("add", "--all", "--", "."); err != nil {
+		return nil, err
+	}
+	out, err := r.git("diff", "--cached", "--name-status", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	changed := nonEmptyLines(out)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	message += "\n\nChanged files:\n"
+	for _, line := range changed {
+		message += strings.Replace(line, "\t", " ", -1) + "\n"
+	}
+	_, err = r.git("commit", "--quiet", "--message", message)
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// changedSince returns the files of the work tree which differ from the
+// given ref, including the uncommitted changes, and the untracked files.
+// The files are returned as a set of absolute paths.
+func (r gitRepo) changedSince(ref string) (map[string]bool, error) {
+	changed := make(map[string]bool)
+	diff, err := git(r.Root, "diff", "--name-only", "-z", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(r.Root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(diff+untracked, "\x00") {
+		if file != "" {
+			changed[filepath.Join(r.Root, filepath.FromSlash(file))] = true
+		}
+	}
+	return changed, nil
+}
+
+// filterChanged keeps the files which are in the changed set.
+func filterChanged(files []string, changed map[string]bool) []string {
+	var res []string
+	for _, file := range files {
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		if !changed[absPath] {
+			// The work tree root returned by git has no symbolic link
+			if absPath, err = filepath.EvalSymlinks(absPath); err != nil || !changed[absPath] {
+				continue
+			}
+		}
+		res = append(res, file)
+	}
+	return res
+}
+
+// commitMessage generates the message of a commit applying the given
+// transformations of a transformation file.
+func commitMessage(subject string, transformations []transform.Transformation) string {
+	var buf bytes.Buffer
+	buf.WriteString(subject)
+	buf.WriteString("\n\nTransformations:\n")
+	for _, transf := range transformations {
+		if transf.Name != "" {
+			fmt.Fprintf(&buf, "- %s (%s)\n", transf.Name, describe(transf))
+		} else {
+			fmt.Fprintf(&buf, "- %s\n", describe(transf))
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// describe summarizes a transformation with its filter and procedures.
+func describe(transf transform.Transformation) string {
+	var procs []string
+	for _, proc := range transf.Proc {
+		procs = append(procs, proc.Name)
+	}
+	return fmt.Sprintf("%s: %s", transf.Filter, strings.Join(procs, ", "))
+}
+
+// postCommitMessage returns the message of the commit of the changes made
+// by the post commands.
+func postCommitMessage(subject string, post []transform.PostCommand) string {
+	var buf bytes.Buffer
+	buf.WriteString(subject)
+	buf.WriteString("\n\nPost commands:\n")
+	for _, p := range post {
+		filter := p.Filter
+		if filter == "" {
+			filter = "*"
+		}
+		fmt.Fprintf(&buf, "- %s: %s\n", filter, strings.Join(p.Command, " "))
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTdfs writes the given files in a temporary directory and returns it.
+func writeTdfs(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "seed-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// initGitRepo creates a git work tree in a temporary directory with
+// the given files committed.
+func initGitRepo(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := writeTdfs(t, files)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.name", "Seed Test"},
+		{"config", "user.email", "seed@example.com"},
+		{"config", "commit.gpgsign", "false"},
+		{"add", "--all"},
+		{"commit", "--quiet", "--message", "Initial commit"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGitRepo(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{"pom.xml": "<groupId>old</groupId>", "src/App.java": "class App {}"})
+	defer os.RemoveAll(dir)
+
+	repo, err := openGitRepo(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := filepath.EvalSymlinks(dir); repo.Root != root && repo.Root != dir {
+		t.Errorf("The root of the work tree should be %s but found %s", dir, repo.Root)
+	}
+
+	changes, err := repo.changes()
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("The work tree should be clean but found %v, %v", changes, err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "pom.xml"), []byte("<groupId>new</groupId>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ = repo.changes(); len(changes) != 1 {
+		t.Errorf("The work tree should have one change but found %v", changes)
+	}
+
+	repo.Dir = dir
+	if err = repo.createBranch("migration"); err != nil {
+		t.Fatal(err)
+	}
+	transformations := []transform.Transformation{transform.Transformation{Filter: "pom.xml", Proc: []transform.Procedure{transform.Procedure{Name: "Replace"}}}}
+	changed, err := repo.commit(commitMessage("Apply tdf.yml", transformations))
+	if err != nil || len(changed) != 1 || changed[0] != "M\tpom.xml" {
+		t.Fatalf("pom.xml should be committed but found %v, %v", changed, err)
+	}
+
+	branch, _ := repo.git("rev-parse", "--abbrev-ref", "HEAD")
+	if strings.TrimSpace(branch) != "migration" {
+		t.Errorf("The commit should be on the migration branch but found %s", branch)
+	}
+	message, _ := repo.git("log", "-1", "--format=%B")
+	if !strings.HasPrefix(message, "Apply tdf.yml\n\nTransformations:\n- pom.xml: Replace\n\nChanged files:\nM pom.xml") {
+		t.Errorf("Unexpected commit message:\n%s", message)
+	}
+
+	if changed, err = repo.commit("Nothing"); err != nil || changed != nil {
+		t.Errorf("Nothing should be committed but found %v, %v", changed, err)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{".gitignore": "*.log\n", "a.txt": "a", "b.txt": "b", "src/c.txt": "c"})
+	defer os.RemoveAll(dir)
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := repo.git("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "a2", "src/c.txt": "c2", "src/d.txt": "d", "app.log": "log"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = repo.commit("Update a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "src", "e.txt"), []byte("e"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := repo.changedSince(strings.TrimSpace(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, errs := transform.Walk(dir, "", "", transform.Options{})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	var all []string
+	for _, f := range files {
+		if !strings.Contains(f, string(filepath.Separator)+".git"+string(filepath.Separator)) {
+			all = append(all, f)
+		}
+	}
+
+	var res []string
+	for _, f := range filterChanged(all, changed) {
+		rel, _ := filepath.Rel(dir, f)
+		res = append(res, filepath.ToSlash(rel))
+	}
+	if strings.Join(res, ",") != "a.txt,src/c.txt,src/d.txt,src/e.txt" {
+		t.Errorf("Only the changed and untracked files should be kept but found %v", res)
+	}
+}
+
+func TestCommitTransformations(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{"a.txt": "foo\nbar\n"})
+	defer os.RemoveAll(dir)
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tdf := transform.T{Transformations: []transform.Transformation{
+		transform.Transformation{Name: "upper", Filter: "*.txt", Proc: []transform.Procedure{transform.Procedure{Name: "Replace", Params: []string{"foo", "FOO"}}}},
+		transform.Transformation{Filter: "*.txt", Proc: []transform.Procedure{transform.Procedure{Name: "Replace", Params: []string{"bar", "BAR"}}}},
+	}}
+
+	var out bytes.Buffer
+	gitWorkTree, commitMode, transPath, dirPath = &repo, "transformation", "tdf.yml", dir
+	onceApplied, runReport = transform.NewOnceState(dir), &transform.Report{}
+	runReviewer = newReviewer(strings.NewReader("a\nn\n"), &out)
+	defer func() {
+		gitWorkTree, commitMode, transPath, dirPath = nil, "", "", "./"
+		onceApplied, runReport, runReviewer = nil, nil, nil
+	}()
+	startReview(tdf.Transformations)
+	count, errs := commitTransformations([]string{filepath.Join(dir, "a.txt")}, tdf, "tdf.yml")
+	if count != 1 || len(errs) != 0 {
+		t.Fatalf("a.txt should be fixed but found %v, %v", count, errs)
+	}
+
+	// Accepting all the hunks of the first transformation doesn't accept
+	// the ones of the second
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "FOO\nbar\n" {
+		t.Errorf("Only the first transformation should be applied but found %q", data)
+	}
+	if !strings.Contains(out.String(), "transformation tdf.yml#upper") || !strings.Contains(out.String(), "transformation tdf.yml#2") {
+		t.Errorf("Each transformation should be reviewed with its name but found %s", out.String())
+	}
+	var indexes []int
+	for _, f := range runReport.Files() {
+		for _, tr := range f.Transformations {
+			indexes = append(indexes, tr.Index)
+		}
+	}
+	if len(indexes) != 2 || indexes[0]+indexes[1] != 3 {
+		t.Errorf("The transformations should be reported at their position but found %v", indexes)
+	}
+	if message, _ := repo.git("log", "-1", "--format=%s"); strings.TrimSpace(message) != "Apply transformation upper of tdf.yml" {
+		t.Errorf("The first transformation should be committed but found %s", message)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io"
+	"strings"
+)
+
+// helpWidth is the width at which the descriptions are wrapped.
+const helpWidth = 80
+
+// commandHelps are the help texts of the commands.
+var commandHelps = map[string]string{
+	"fix":      fixHelp,
+	"check":    checkHelp,
+	"status":   statusHelp,
+	"rollback": rollbackHelp,
+	"hook":     hookHelp,
+	"validate": validateHelp,
+	"convert":  convertHelp,
+	"version":  versionHelp,
+	"help":     helpHelp,
+}
+
+// printHelp writes the help of a topic: a command, "procs" for all the
+// procedures, "pre" for all the preconditions, or the name of a procedure
+// or a precondition. It returns false if the topic is unknown.
+func printHelp(w io.Writer, topic string) bool {
+	if topic == "" {
+		fmt.Fprintf(w, "%s\n", seedHelp)
+		return true
+	}
+	if help, ok := commandHelps[topic]; ok {
+		fmt.Fprintf(w, "%s\n", help)
+		return true
+	}
+
+	switch topic {
+	case "procs":
+		fmt.Fprint(w, "Procedures of the \"proc\" section of a transformation:\n\n")
+		for _, def := range transform.RegisteredProcedures() {
+			printProcedure(w, def)
+		}
+		return true
+	case "pre":
+		fmt.Fprint(w, "Preconditions of the \"pre\" section of a transformation:\n\n")
+		for _, def := range transform.RegisteredPreconditions() {
+			printPrecondition(w, def)
+		}
+		return true
+	}
+
+	found := false
+	if def, ok := transform.LookupProcedure(topic); ok {
+		printProcedure(w, def)
+		found = true
+	}
+	if def, ok := transform.LookupPrecondition(topic); ok {
+		printPrecondition(w, def)
+		found = true
+	}
+	return found
+}
+
+// printProcedure writes the signature, the description, the parameters
+// and the examples of a procedure.
+func printProcedure(w io.Writer, def transform.ProcedureDef) {
+	var names []string
+	for _, param := range def.Params {
+		name := param.Name
+		if param.Variadic {
+			name += "..."
+		}
+		names = append(names, name)
+	}
+	fmt.Fprintf(w, "%s(%s)\n", def.Name, strings.Join(names, ", "))
+	printDescription(w, def.Description)
+
+	if len(def.Params) > 0 {
+		fmt.Fprint(w, "\n    Parameters:\n")
+		for i, param := range def.Params {
+			fmt.Fprintf(w, "        %-12s %s\n", names[i], param.Description)
+		}
+	}
+	printExamples(w, def.Examples)
+	fmt.Fprintln(w)
+}
+
+// printPrecondition writes the signature, the description, the argument
+// and the examples of a precondition.
+func printPrecondition(w io.Writer, def transform.PreconditionDef) {
+	if def.Arg == nil {
+		fmt.Fprintln(w, def.Name)
+	} else {
+		fmt.Fprintf(w, "%s(%s)\n", def.Name, def.Arg.Name)
+	}
+	printDescription(w, def.Description)
+
+	if def.Arg != nil {
+		fmt.Fprint(w, "\n    Argument:\n")
+		fmt.Fprintf(w, "        %-12s %s\n", def.Arg.Name, def.Arg.Description)
+	}
+	printExamples(w, def.Examples)
+	fmt.Fprintln(w)
+}
+
+func printDescription(w io.Writer, description string) {
+	if description == "" {
+		return
+	}
+	for _, line := range wrapText(description, helpWidth-4) {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
+
+func printExamples(w io.Writer, examples []string) {
+	for _, example := range examples {
+		fmt.Fprint(w, "\n    Example:\n")
+		for _, line := range strings.Split(example, "\n") {
+			fmt.Fprintf(w, "        %s\n", line)
+		}
+	}
+}
+
+// wrapText splits the text in lines of at most width characters, unless
+// a word is longer.
+func wrapText(text string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestHelpProcedure(t *testing.T) {
+	var buf bytes.Buffer
+	if !printHelp(&buf, "Replace") {
+		t.Fatal("Replace should have a help")
+	}
+	expected := `Replace(pairs...)
+    Replaces all the occurrences of strings by new ones. The replacements are
+    applied in order.
+
+    Parameters:
+        pairs...     pairs of an old string and its replacement
+
+    Example:
+        proc:
+          - name: Replace
+            params:
+              - "myStringToModify"
+              - "myModifiedString"
+
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected help:\n%s", buf.String())
+	}
+}
+
+func TestHelpTopics(t *testing.T) {
+	for topic, expected := range map[string]string{
+		"":        "Usage: seed <command> <args>",
+		"convert": "Usage: seed convert",
+		"procs":   "EnsureInsert(string)",
+		"pre":     "AlwaysTrue\n",
+		"Script":  "Script(script)\n",
+	} {
+		var buf bytes.Buffer
+		if !printHelp(&buf, topic) || !strings.Contains(buf.String(), expected) {
+			t.Errorf("The help of %q should contain %q but found:\n%s", topic, expected, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	printHelp(&buf, "Script")
+	if !strings.Contains(buf.String(), "Script(expression)\n") {
+		t.Errorf("The help of Script should describe the precondition too but found:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if printHelp(&buf, "Unknown") {
+		t.Error("Unknown should not have a help")
+	}
+
+	for _, cmd := range []string{"fix", "check", "status", "rollback", "hook", "validate", "convert", "help", "version"} {
+		if !strings.Contains(seedHelp, "    "+cmd+" ") || commandHelps[cmd] == "" {
+			t.Errorf("The command %s should be documented", cmd)
+		}
+	}
+}
+
+func TestFixHelpFlags(t *testing.T) {
+	// The other flags are documented by their command, the test flags are
+	// not flags of seed
+	others := map[string]bool{"sarif": true, "junit": true, "restage": true}
+	flag.VisitAll(func(f *flag.Flag) {
+		if !others[f.Name] && !strings.HasPrefix(f.Name, "test.") && !strings.Contains(fixHelp, "\n -"+f.Name+" ") && !strings.Contains(fixHelp, "\n -"+f.Name+"=") {
+			t.Errorf("The -%s flag should be listed in the help of fix", f.Name)
+		}
+	})
+}
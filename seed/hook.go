@@ -0,0 +1,223 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hookMarker identifies the git hooks installed by seed, the other
+// hooks are not replaced unless forced.
+const hookMarker = "# Installed by seed"
+
+// stagedFix is a staged file which needs to be fixed.
+type stagedFix struct {
+	// Path is the path of the file relative to the top of the work tree
+	Path string
+	// Diff is the fix of the staged content in the unified format
+	Diff string
+}
+
+// stagedFiles returns the files of the directory which are added, copied,
+// modified or renamed in the index. The paths are relative to the top of
+// the work tree.
+func (r gitRepo) stagedFiles() ([]string, error) {
+	out, err := r.git("diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// stagedContent returns the content of the file in the index.
+func (r gitRepo) stagedContent(path string) ([]byte, error) {
+	return gitWithInput(r.Root, nil, "cat-file", "blob", ":"+path)
+}
+
+// stage replaces the content of the file in the index, keeping its mode.
+func (r gitRepo) stage(path string, data []byte) error {
+	sha, err := gitWithInput(r.Root, data, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	entry, err := git(r.Root, "ls-files", "--stage", "--", path)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is not in the index", path)
+	}
+	info := fmt.Sprintf("%s,%s,%s", fields[0], strings.TrimSpace(string(sha)), path)
+	_, err = git(r.Root, "update-index", "--cacheinfo", info)
+	return err
+}
+
+// fixStaged applies the transformations on the staged content of the staged
+// files and returns the files which need to be fixed. The files skipped by
+// a run on the work tree are skipped. If restage is true, the fixed content
+// is staged, and written in the work tree when the file has no unstaged
+// changes, and the once markers are saved.
+func fixStaged(repo gitRepo, t transform.T, tdfPath string, restage bool, opts transform.Options) ([]stagedFix, error) {
+	files, err := repo.stagedFiles()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		if !excludedPath(file, t.Exclude) && filepath.Base(file) != filepath.Base(tdfPath) {
+			paths = append(paths, filepath.Join(repo.Root, filepath.FromSlash(file)))
+		}
+	}
+	if paths, err = transform.Unignored(repo.Root, paths, opts); err != nil {
+		return nil, err
+	}
+
+	var fixes []stagedFix
+	for _, absPath := range paths {
+		rel, err := filepath.Rel(repo.Root, absPath)
+		if err != nil {
+			return nil, err
+		}
+		file := filepath.ToSlash(rel)
+		staged, err := repo.stagedContent(file)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := transform.Apply(absPath, staged, t, opts)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(staged, data) {
+			continue
+		}
+		fixes = append(fixes, stagedFix{Path: file, Diff: transform.UnifiedDiff("a/"+file, "b/"+file, staged, data)})
+		if !restage {
+			continue
+		}
+
+		if err = repo.stage(file, data); err != nil {
+			return nil, err
+		}
+		// Keep the unstaged changes of the work tree
+		if current, err := ioutil.ReadFile(absPath); err == nil && bytes.Equal(current, staged) {
+			if err = writeFile(absPath, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if restage && len(fixes) > 0 && opts.Once != nil {
+		if err = opts.Once.Save(writeFile); err != nil {
+			return nil, err
+		}
+	}
+	return fixes, nil
+}
+
+// excludedPath returns true if the file is in the state directory of seed
+// or in a directory matching the exclusion patterns.
+func excludedPath(path string, excludes string) bool {
+	dirs := strings.Split(filepath.ToSlash(path), "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if dir == stateDir {
+			return true
+		}
+		for _, patt := range strings.Split(excludes, "|") {
+			if match, _ := filepath.Match(patt, dir); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// installHook writes a pre-commit hook running "seed hook run" with the
+// given arguments in the git repository. An existing hook which was not
+// installed by seed is only replaced if force is true.
+func installHook(repo gitRepo, args []string, force bool) (string, error) {
+	hooks, err := repo.git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.FromSlash(strings.TrimSpace(hooks))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo.Dir, dir)
+	}
+	path := filepath.Join(dir, "pre-commit")
+
+	if dat, err := ioutil.ReadFile(path); err == nil && !force && !bytes.Contains(dat, []byte(hookMarker)) {
+		return "", fmt.Errorf("%s already exists, use -f to replace it", path)
+	}
+
+	seed, err := os.Executable()
+	if err != nil {
+		seed = "seed"
+	}
+	var quoted []string
+	for _, arg := range append([]string{seed}, args...) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s, see \"seed help hook\".\nexec %s hook run\n", hookMarker, strings.Join(quoted, " "))
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return path, ioutil.WriteFile(path, []byte(script), 0755)
+}
+
+// hookArgs returns the flags of the current command to pass to the hook,
+// with absolute paths.
+func hookArgs(tdfPath, varsPath string, vars map[string]string, restage bool) ([]string, error) {
+	var args []string
+	if !transform.IsURL(tdfPath) {
+		absPath, err := filepath.Abs(tdfPath)
+		if err != nil {
+			return nil, err
+		}
+		tdfPath = absPath
+	}
+	args = append(args, "-t", tdfPath)
+	if varsPath != "" {
+		absPath, err := filepath.Abs(varsPath)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-vars", absPath)
+	}
+	var keys []string
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-var", key+"="+vars[key])
+	}
+	if restage {
+		args = append(args, "-restage")
+	}
+	return args, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
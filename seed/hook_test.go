@@ -0,0 +1,167 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var hookTdf = transform.T{
+	Exclude: "target",
+	Transformations: []transform.Transformation{
+		transform.Transformation{Filter: "*.txt", Proc: []transform.Procedure{transform.Procedure{Name: "Replace", Params: []string{"old", "new"}}}},
+	},
+}
+
+func stageFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := git(dir, "add", "--", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFixStaged(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "old", "target/d.txt": "d"})
+	defer os.RemoveAll(dir)
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageFiles(t, dir, map[string]string{"a.txt": "old a\n", "b.txt": "old b\n", "target/d.txt": "old d\n"})
+	// The unstaged changes are not transformed
+	if err = ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("old b\nunstaged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fixes, err := fixStaged(repo, hookTdf, "tdf.yml", false, transform.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 2 || fixes[0].Path != "a.txt" || fixes[1].Path != "b.txt" {
+		t.Fatalf("The staged files a.txt and b.txt should be fixed but found %v", fixes)
+	}
+	if fixes[0].Diff != "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-old a\n+new a\n" {
+		t.Errorf("Unexpected diff:\n%s", fixes[0].Diff)
+	}
+	if staged, _ := repo.stagedContent("a.txt"); string(staged) != "old a\n" {
+		t.Errorf("The index should not be modified but found %q", staged)
+	}
+
+	if fixes, err = fixStaged(repo, hookTdf, "tdf.yml", true, transform.Options{}); err != nil || len(fixes) != 2 {
+		t.Fatalf("The staged files should be fixed but found %v, %v", fixes, err)
+	}
+	if staged, _ := repo.stagedContent("a.txt"); string(staged) != "new a\n" {
+		t.Errorf("The fixed content of a.txt should be staged but found %q", staged)
+	}
+	if staged, _ := repo.stagedContent("b.txt"); string(staged) != "new b\n" {
+		t.Errorf("The fixed content of b.txt should be staged but found %q", staged)
+	}
+	if readContent(t, filepath.Join(dir, "a.txt")) != "new a\n" {
+		t.Error("The fixed content of a.txt should be written in the work tree")
+	}
+	if readContent(t, filepath.Join(dir, "b.txt")) != "old b\nunstaged\n" {
+		t.Error("The unstaged changes of b.txt should be kept")
+	}
+}
+
+func TestFixStagedIgnoredAndOnce(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{".gitignore": "*.gen\n", ".seedignore": "skip/\n", "a.txt": "a"})
+	defer os.RemoveAll(dir)
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "old a\n", "b.gen": "old b\n", "skip/c.txt": "old c\n"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = git(dir, "add", "--force", "--", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tdf := transform.T{Transformations: []transform.Transformation{
+		transform.Transformation{Filter: "*", Once: true, Proc: []transform.Procedure{transform.Procedure{Name: "Insert", Params: []string{"header\n"}}}},
+	}}
+	opts := transform.Options{GitIgnore: true, Once: transform.NewOnceState(dir)}
+	fixes, err := fixStaged(repo, tdf, "tdf.yml", true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 || fixes[0].Path != "a.txt" {
+		t.Fatalf("Only the staged a.txt should be fixed, the ignored files are skipped, but found %v", fixes)
+	}
+
+	// The once markers are saved, so the transformation is not applied again
+	state, err := transform.ReadOnceState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixes, err = fixStaged(repo, tdf, "tdf.yml", true, transform.Options{GitIgnore: true, Once: state}); err != nil || len(fixes) != 0 {
+		t.Errorf("The once transformation should not be applied again but found %v, %v", fixes, err)
+	}
+	if staged, _ := repo.stagedContent("a.txt"); string(staged) != "old a\nheader\n" {
+		t.Errorf("The fixed content of a.txt should be staged once but found %q", staged)
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{"a.txt": "a"})
+	defer os.RemoveAll(dir)
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := hookArgs("/tdf/it's.yml", "", map[string]string{"b": "2", "a": "1"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := installHook(repo, args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := readContent(t, path)
+	if !strings.HasSuffix(script, `'-t' '/tdf/it'\''s.yml' '-var' 'a=1' '-var' 'b=2' '-restage' hook run`+"\n") {
+		t.Errorf("Unexpected hook script:\n%s", script)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode()&0100 == 0 {
+		t.Errorf("The hook should be executable but found %v, %v", info, err)
+	}
+
+	// A hook installed by seed is replaced, but not the other hooks
+	if _, err = installHook(repo, args, false); err != nil {
+		t.Errorf("The seed hook should be replaced but found %v", err)
+	}
+	if err = ioutil.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = installHook(repo, args, false); err == nil {
+		t.Error("An existing hook should not be replaced")
+	}
+	if _, err = installHook(repo, args, true); err != nil {
+		t.Errorf("An existing hook should be replaced when forced but found %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io"
+	"strings"
+)
+
+var interactive bool
+
+// runReviewer asks the user to accept the changes of the run with -i.
+var runReviewer *reviewer
+
+const reviewHelp = `y - apply this hunk
+n - do not apply this hunk
+a - apply this hunk and all the next hunks of this transformation
+q - quit, do not apply this hunk nor any of the next ones
+? - print help
+`
+
+// reviewer shows each hunk of the transformations and asks whether to
+// apply it.
+type reviewer struct {
+	in  *bufio.Reader
+	out io.Writer
+	// ids names the transformations of the current transformation file
+	ids []string
+	// all are the transformations whose hunks are all accepted
+	all  map[int]bool
+	quit bool
+}
+
+func newReviewer(in io.Reader, out io.Writer) *reviewer {
+	return &reviewer{in: bufio.NewReader(in), out: out, all: make(map[int]bool)}
+}
+
+// review asks the user which hunks of the transformation to apply on the
+// file. Once the user quits, or the input ends, the hunks are rejected.
+func (r *reviewer) review(file string, index int, t transform.Transformation, hunks []transform.Hunk) []bool {
+	accepted := make([]bool, len(hunks))
+	for i, h := range hunks {
+		if r.quit {
+			break
+		}
+		if r.all[index] {
+			accepted[i] = true
+			continue
+		}
+
+		name := fmt.Sprintf("%v", index)
+		if index <= len(r.ids) {
+			name = r.ids[index-1]
+		}
+		fmt.Fprintf(r.out, "\n%s: transformation %s (%s), hunk %v/%v\n%s", transform.ShortPath(file), name, describe(t), i+1, len(hunks), h.Diff)
+		accepted[i] = r.ask(index)
+	}
+	return accepted
+}
+
+// ask asks whether to apply a hunk of the transformation until the user
+// gives a valid answer.
+func (r *reviewer) ask(index int) bool {
+	for {
+		fmt.Fprint(r.out, "Apply this hunk [y,n,a,q,?]? ")
+		answer, err := r.in.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(r.out)
+			answer = "q"
+		}
+		switch strings.TrimSpace(answer) {
+		case "y":
+			return true
+		case "n":
+			return false
+		case "a":
+			r.all[index] = true
+			return true
+		case "q":
+			r.quit = true
+			fmt.Fprintln(r.out, "The next changes are not applied.")
+			return false
+		default:
+			fmt.Fprint(r.out, reviewHelp)
+		}
+	}
+}
+
+// startReview reviews the changes of the transformation file, whose
+// transformations are named like in the reports.
+func startReview(transformations []transform.Transformation) {
+	if runReviewer != nil {
+		runReviewer.ids = ruleIDs(transformations)
+		runReviewer.all = make(map[int]bool)
+	}
+}
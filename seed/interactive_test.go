@@ -0,0 +1,71 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"github.com/seedstack/tools/transform"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReviewer(t *testing.T) {
+	var out bytes.Buffer
+	r := newReviewer(strings.NewReader("?\ny\nn\na\ny\nq\n"), &out)
+	r.ids = []string{"tdf.yml#1", "tdf.yml#2"}
+	first := transform.Transformation{Filter: "*.java", Proc: []transform.Procedure{transform.Procedure{Name: "Replace"}}}
+	second := transform.Transformation{Filter: "*.xml", Proc: []transform.Procedure{transform.Procedure{Name: "Insert"}}}
+	hunks := func(n int) []transform.Hunk {
+		res := make([]transform.Hunk, n)
+		for i := range res {
+			res[i].Diff = "@@ -1 +1 @@\n-a\n+b\n"
+		}
+		return res
+	}
+
+	for _, c := range []struct {
+		index    int
+		transf   transform.Transformation
+		hunks    int
+		expected []bool
+	}{
+		// "?" shows the help, then y, n, and a accepts the rest of the transformation
+		{1, first, 4, []bool{true, false, true, true}},
+		{1, first, 1, []bool{true}},
+		{2, second, 2, []bool{true, false}},
+		// After q, nothing is asked
+		{2, second, 1, []bool{false}},
+	} {
+		if res := r.review("App.java", c.index, c.transf, hunks(c.hunks)); !reflect.DeepEqual(res, c.expected) {
+			t.Errorf("The accepted hunks of transformation %v should be %v but found %v", c.index, c.expected, res)
+		}
+	}
+
+	output := out.String()
+	for _, expected := range []string{
+		"App.java: transformation tdf.yml#1 (*.java: Replace), hunk 1/4\n@@ -1 +1 @@\n-a\n+b\nApply this hunk [y,n,a,q,?]? ",
+		"a - apply this hunk and all the next hunks of this transformation",
+		"App.java: transformation tdf.yml#2 (*.xml: Insert), hunk 2/2",
+		"The next changes are not applied.",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("The output should contain %q but found:\n%s", expected, output)
+		}
+	}
+	if strings.Count(output, "Apply this hunk") != 6 {
+		t.Errorf("The reviewer should ask 6 times but found:\n%s", output)
+	}
+
+	// The end of the input stops the review
+	r = newReviewer(strings.NewReader(""), &out)
+	if res := r.review("App.java", 1, first, hunks(2)); !reflect.DeepEqual(res, []bool{false, false}) || !r.quit {
+		t.Errorf("The hunks should be rejected at the end of the input but found %v", res)
+	}
+}
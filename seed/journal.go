@@ -0,0 +1,256 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const runsDir = "runs"
+const journalFile = "journal.yml"
+
+// runJournal records the files modified by the current run.
+// It is nil when the run is not journaled.
+var runJournal *journal
+
+// journal records the original content of the files modified by a run,
+// in the ".seed/runs/<run-id>" directory of the project, so the run can
+// be rolled back.
+type journal struct {
+	mu   sync.Mutex
+	root string
+	// tdf is the transformation file currently applied
+	tdf   string
+	ID    string
+	Date  string
+	Files []journalEntry
+}
+
+// journalEntry describes a file modified by a run. The original content
+// is saved in the Backup file of the run directory, unless the file was
+// created by the run.
+type journalEntry struct {
+	Path         string
+	Tdf          string
+	Date         string
+	Created      bool
+	Backup       string
+	OriginalHash string
+	Hash         string
+}
+
+func newJournal(root string) (*journal, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &journal{
+		root: absRoot,
+		ID:   now.Format("20060102-150405.000"),
+		Date: now.Format(time.RFC3339),
+	}, nil
+}
+
+func (j *journal) dir() string {
+	return filepath.Join(j.root, stateDir, runsDir, j.ID)
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// record saves the original content of the file before it is replaced
+// by data. A file modified several times keeps its first original content.
+func (j *journal) record(path string, data []byte) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	relPath, err := filepath.Rel(j.root, absPath)
+	if err != nil {
+		return err
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, entry := range j.Files {
+		if entry.Path == relPath {
+			j.Files[i].Hash = hash(data)
+			return nil
+		}
+	}
+
+	entry := journalEntry{Path: relPath, Tdf: j.tdf, Date: time.Now().Format(time.RFC3339), Hash: hash(data)}
+	orig, err := ioutil.ReadFile(absPath)
+	switch {
+	case os.IsNotExist(err):
+		entry.Created = true
+	case err != nil:
+		return err
+	default:
+		entry.OriginalHash = hash(orig)
+		entry.Backup = fmt.Sprintf("files/%05d", len(j.Files))
+		if err = os.MkdirAll(filepath.Join(j.dir(), "files"), 0755); err != nil {
+			return err
+		}
+		if err = ignoreRuns(j.root); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(j.dir(), filepath.FromSlash(entry.Backup)), orig, 0644); err != nil {
+			return err
+		}
+	}
+	j.Files = append(j.Files, entry)
+	return nil
+}
+
+// save writes the journal in the run directory if files were modified.
+func (j *journal) save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.Files) == 0 {
+		return nil
+	}
+	dat, err := yaml.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(j.dir(), 0755); err != nil {
+		return err
+	}
+	if err = ignoreRuns(j.root); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(j.dir(), journalFile), dat, 0644)
+}
+
+// ignoreRuns prevents the journals from being committed, while the rest
+// of the state of seed in the project can be.
+func ignoreRuns(root string) error {
+	path := filepath.Join(root, stateDir, ".gitignore")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(runsDir+"/\n"), 0644)
+}
+
+// writeFile replaces the content of the file after recording its original
+// content in the journal of the run. With -o, the content is only added to
+// the patch of the run.
+func writeFile(path string, data []byte) error {
+	if runPatch != nil {
+		return runPatch.add(path, data)
+	}
+	if runJournal != nil {
+		if err := runJournal.record(path, data); err != nil {
+			return err
+		}
+	}
+	// The state files of seed may be written in a new directory
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// listRuns returns the identifiers of the journaled runs of the project,
+// from the oldest to the most recent.
+func listRuns(root string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(root, stateDir, runsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, info := range infos {
+		if info.IsDir() {
+			runs = append(runs, info.Name())
+		}
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+func readJournal(root, id string) (*journal, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{root: absRoot, ID: id}
+	dat, err := ioutil.ReadFile(filepath.Join(j.dir(), journalFile))
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(dat, j); err != nil {
+		return nil, err
+	}
+	j.root = absRoot
+	j.ID = id
+	return j, nil
+}
+
+// rollback restores the original content of the files modified by the run.
+// The files edited again after the run are not restored unless force is
+// true, they are returned so the run can be rolled back later. The run
+// directory is removed when all its files are restored.
+func (j *journal) rollback(force bool) ([]string, error) {
+	var edited []string
+	var remaining []journalEntry
+
+	for _, entry := range j.Files {
+		path := filepath.Join(j.root, filepath.FromSlash(entry.Path))
+		current, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return edited, err
+		}
+		if !force && (err != nil || hash(current) != entry.Hash) {
+			edited = append(edited, entry.Path)
+			remaining = append(remaining, entry)
+			continue
+		}
+
+		if entry.Created {
+			err = os.Remove(path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			var orig []byte
+			orig, err = ioutil.ReadFile(filepath.Join(j.dir(), filepath.FromSlash(entry.Backup)))
+			if err == nil {
+				err = ioutil.WriteFile(path, orig, 0644)
+			}
+		}
+		if err != nil {
+			return edited, err
+		}
+	}
+
+	if len(remaining) > 0 {
+		j.Files = remaining
+		return edited, j.save()
+	}
+	return edited, os.RemoveAll(j.dir())
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func journaledRun(t *testing.T, dir string) *journal {
+	j, err := newJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.tdf = "tdf.yml"
+
+	runJournal = j
+	defer func() { runJournal = nil }()
+
+	if err = writeFile(filepath.Join(dir, "a.txt"), []byte("a1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeFile(filepath.Join(dir, "a.txt"), []byte("a2")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeFile(filepath.Join(dir, "b.txt"), []byte("b1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeFile(filepath.Join(dir, "new.txt"), []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err = j.save(); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func readContent(t *testing.T, path string) string {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "<missing>"
+	}
+	return string(dat)
+}
+
+func TestJournal(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"a.txt": "a0", "b.txt": "b0"})
+	defer os.RemoveAll(dir)
+
+	j := journaledRun(t, dir)
+
+	runs, err := listRuns(dir)
+	if err != nil || len(runs) != 1 || runs[0] != j.ID {
+		t.Fatalf("The run %s should be listed but found %v, %v", j.ID, runs, err)
+	}
+
+	saved, err := readJournal(dir, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Files) != 3 {
+		t.Fatalf("3 files should be journaled but found %v", saved.Files)
+	}
+	if saved.Files[0].Path != "a.txt" || saved.Files[0].Hash != hash([]byte("a2")) || saved.Files[0].OriginalHash != hash([]byte("a0")) {
+		t.Errorf("a.txt should keep its first original content and its last hash but found %v", saved.Files[0])
+	}
+	if saved.Files[0].Tdf != "tdf.yml" || !saved.Files[2].Created {
+		t.Errorf("The entries should record the transformation file and the created files but found %v", saved.Files)
+	}
+
+	edited, err := saved.rollback(false)
+	if err != nil || len(edited) != 0 {
+		t.Fatalf("The run should be rolled back but found %v, %v", edited, err)
+	}
+	if readContent(t, filepath.Join(dir, "a.txt")) != "a0" || readContent(t, filepath.Join(dir, "b.txt")) != "b0" {
+		t.Error("The original files should be restored")
+	}
+	if readContent(t, filepath.Join(dir, "new.txt")) != "<missing>" {
+		t.Error("The files created by the run should be removed")
+	}
+	if runs, _ = listRuns(dir); len(runs) != 0 {
+		t.Errorf("The rolled back run should be removed but found %v", runs)
+	}
+}
+
+func TestRollbackEditedFiles(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"a.txt": "a0", "b.txt": "b0"})
+	defer os.RemoveAll(dir)
+
+	j := journaledRun(t, dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := readJournal(dir, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := saved.rollback(false)
+	if err != nil || len(edited) != 1 || edited[0] != "b.txt" {
+		t.Fatalf("b.txt should not be restored but found %v, %v", edited, err)
+	}
+	if readContent(t, filepath.Join(dir, "a.txt")) != "a0" || readContent(t, filepath.Join(dir, "b.txt")) != "edited" {
+		t.Error("Only the files not edited after the run should be restored")
+	}
+
+	saved, err = readJournal(dir, j.ID)
+	if err != nil || len(saved.Files) != 1 {
+		t.Fatalf("The run should keep the file not restored but found %v, %v", saved, err)
+	}
+	if _, err = saved.rollback(true); err != nil {
+		t.Fatal(err)
+	}
+	if readContent(t, filepath.Join(dir, "b.txt")) != "b0" {
+		t.Error("The edited file should be restored when forced")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"path/filepath"
+	"strings"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemErr string      `xml:"system-err,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport returns the JUnit XML report of a check run, with a test
+// suite per transformation file and a test case per transformation. A
+// test case fails if the transformation would modify files, and is in
+// error if it failed on files. The other errors of the run are written in
+// the system-err of the first suite.
+func junitReport(r report, transformations []transform.Transformation) ([]byte, error) {
+	ids := ruleIDs(transformations)
+	fixed := make([][]string, len(transformations))
+	failed := make([][]string, len(transformations))
+	times := make([]float64, len(transformations))
+	for _, f := range r.Files {
+		for _, tr := range f.Transformations {
+			if tr.Index > len(transformations) {
+				continue
+			}
+			i := tr.Index - 1
+			times[i] += tr.DurationMs
+			if len(tr.Changes) > 0 {
+				fixed[i] = append(fixed[i], fmt.Sprintf("%s: %s", f.File, linesText(tr.Changes)))
+			}
+			if tr.Error != "" {
+				failed[i] = append(failed[i], fmt.Sprintf("%s: %s", f.File, tr.Error))
+			}
+		}
+	}
+
+	suites := junitSuites{Name: "seed", Time: seconds(r.DurationMs)}
+	suiteOf := make(map[string]int)
+	var suiteTimes []float64
+	for i, transf := range transformations {
+		source := filepath.Base(transPath)
+		if transf.Source != "" {
+			source = filepath.Base(transf.Source)
+		}
+		s, ok := suiteOf[source]
+		if !ok {
+			s = len(suites.Suites)
+			suiteOf[source] = s
+			suites.Suites = append(suites.Suites, junitSuite{Name: source})
+			suiteTimes = append(suiteTimes, 0)
+		}
+		suite := &suites.Suites[s]
+		suiteTimes[s] += times[i]
+
+		c := junitCase{
+			Name:      fmt.Sprintf("%s %s", ids[i], describe(transf)),
+			ClassName: source,
+			Time:      seconds(times[i]),
+		}
+		if len(fixed[i]) > 0 {
+			c.Failure = &junitProblem{
+				Message: fmt.Sprintf("%v files should be fixed", len(fixed[i])),
+				Type:    "seed.check",
+				Text:    strings.Join(fixed[i], "\n"),
+			}
+			suite.Failures++
+		}
+		if len(failed[i]) > 0 {
+			c.Error = &junitProblem{
+				Message: fmt.Sprintf("the transformation failed on %v files", len(failed[i])),
+				Type:    "seed.error",
+				Text:    strings.Join(failed[i], "\n"),
+			}
+			suite.Errors++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		suite.Time = seconds(suiteTimes[i])
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+	}
+	if len(r.Errors) > 0 && len(suites.Suites) > 0 {
+		suites.Suites[0].SystemErr = strings.Join(r.Errors, "\n")
+	}
+
+	dat, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bytes.TrimSpace(dat)...), nil
+}
+
+// seconds formats a duration in milliseconds in seconds.
+func seconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
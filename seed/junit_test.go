@@ -0,0 +1,49 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestJUnitReport(t *testing.T) {
+	dat, err := junitReport(checkedRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitSuites
+	if err = xml.Unmarshal(dat, &suites); err != nil {
+		t.Fatalf("The JUnit report should be valid XML but found %v", err)
+	}
+
+	if suites.Tests != 3 || suites.Failures != 1 || suites.Errors != 1 || suites.Time != "1.500" {
+		t.Errorf("The report should count the transformations but found %+v", suites)
+	}
+	if len(suites.Suites) != 2 || suites.Suites[0].Name != "maven.yml" || suites.Suites[1].Name != "tdf.yml" {
+		t.Fatalf("The report should have a suite per transformation file but found %+v", suites.Suites)
+	}
+	if suites.Suites[0].SystemErr == "" || suites.Suites[0].Failures != 0 || suites.Suites[0].Cases[0].Failure != nil {
+		t.Errorf("The maven transformation should pass with the errors of the run but found %+v", suites.Suites[0])
+	}
+
+	cases := suites.Suites[1].Cases
+	if cases[0].Name != "tdf.yml#1 *.java: Replace, Insert" || cases[0].ClassName != "tdf.yml" {
+		t.Errorf("The test case should be named after the transformation but found %+v", cases[0])
+	}
+	if f := cases[0].Failure; f == nil || f.Message != "1 files should be fixed" || f.Text != "src/App.java: lines 1, 4-6" {
+		t.Errorf("The first java transformation should fail with the file to fix but found %+v", f)
+	}
+	if e := cases[1].Error; cases[1].Failure != nil || e == nil || e.Text != "src/Bad.java: Plugin: failed" {
+		t.Errorf("The second java transformation should be in error but found %+v", cases[1])
+	}
+	if suites.Suites[1].Time != "0.024" || cases[0].Time != "0.010" || cases[1].Time != "0.014" {
+		t.Errorf("The time of each transformation should be reported but found %s, %+v", suites.Suites[1].Time, cases)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// runPatch collects the files fixed with -o instead of writing them.
+var runPatch *patch
+
+// patch is the new content of the files modified by a run.
+type patch struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newPatch() *patch {
+	return &patch{files: make(map[string][]byte)}
+}
+
+// add records the new content of the file, which is not modified.
+func (p *patch) add(path string, data []byte) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files[absPath] = data
+	return nil
+}
+
+// format returns the changes of the files as a git patch, with the paths
+// relative to root. The files are compared with their current content,
+// the files which don't exist are created by the patch. It returns the
+// number of files in the patch.
+func (p *patch) format(root string) ([]byte, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, 0, err
+	}
+	var paths []string
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	count := 0
+	for _, path := range paths {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, 0, err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		oldName := "a/" + relPath
+		orig, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return nil, 0, err
+		}
+		diff := transform.UnifiedDiff(oldName, "b/"+relPath, orig, p.files[path])
+		if diff == "" {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "diff --git a/%s b/%s\n", relPath, relPath)
+		if oldName == "/dev/null" {
+			buf.WriteString("new file mode 100644\n")
+		}
+		buf.WriteString(diff)
+		count++
+	}
+	return buf.Bytes(), count, nil
+}
+
+// startPatch collects the changes of the run in a patch instead of
+// writing the files. The patch only supports the plain fix of a directory.
+func startPatch() {
+	if checkOnly || verifyIdempotent {
+		log.Fatal("The patch is only written by the fix command.")
+	}
+	if info, err := os.Stat(transPath); err == nil && info.IsDir() {
+		log.Fatal("The patch doesn't support migration chains, the migrations depend on the files written by the previous ones.")
+	}
+	if useGit || gitBranch != "" || commitMode != "" {
+		log.Fatal("The patch can't be committed, don't use -git, -branch or -commit with -o.")
+	}
+	runPatch = newPatch()
+}
+
+// patchRoot returns the directory the paths of the patch are relative to:
+// the top level of the git work tree of the fixed directory, so the patch
+// can be applied with "git apply", or the fixed directory outside of git.
+func patchRoot() string {
+	if repo, err := openGitRepo(dirPath); err == nil {
+		return repo.Root
+	}
+	return dirPath
+}
+
+// savePatch writes the patch of the run with -o.
+func savePatch() {
+	if runPatch == nil {
+		return
+	}
+	dat, count, err := runPatch.format(patchRoot())
+	if err == nil {
+		err = ioutil.WriteFile(outputPath, dat, 0644)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write the patch %s: %s", outputPath, err)
+	}
+	fmt.Printf("Wrote the changes of %v files to %s\n", count, outputPath)
+}
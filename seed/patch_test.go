@@ -0,0 +1,87 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{
+		"app/pom.xml":      "<project>\n<version>1</version>\n</project>\n",
+		"app/src/App.java": "import javax.inject;\n",
+		"app/README.md":    "# App\n",
+		"app/NOTICE":       "Notice\n",
+	})
+	defer os.RemoveAll(dir)
+
+	p := newPatch()
+	runPatch = p
+	defer func() { runPatch, onceApplied = nil, nil }()
+	state, err := transform.ReadOnceState(filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	onceApplied = state
+	tr := transform.T{Transformations: []transform.Transformation{transform.Transformation{Filter: "NOTICE", Once: true,
+		Proc: []transform.Procedure{transform.Procedure{Name: "Insert", Params: []string{"Copyright\n"}}}}}}
+	transform.Fix([]string{filepath.Join(dir, "app", "NOTICE")}, tr, options())
+	if err = onceApplied.Save(writeFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "app", ".seed")); !os.IsNotExist(err) {
+		t.Errorf("The state directory shouldn't be created in the project but found %v", err)
+	}
+
+	p.add(filepath.Join(dir, "app", "pom.xml"), []byte("<project>\n<version>2</version>\n</project>\n"))
+	p.add(filepath.Join(dir, "app", "src", "App.java"), []byte("import jakarta.inject;\n"))
+	p.add(filepath.Join(dir, "app", "README.md"), []byte("# App\n"))
+	p.add(filepath.Join(dir, "app", "src", "App.java"), []byte("import jakarta.inject;\nimport jakarta.ws;\n"))
+
+	dat, count, err := p.format(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("The unchanged file shouldn't be in the patch but found %v files:\n%s", count, dat)
+	}
+	for _, expected := range []string{
+		"diff --git a/app/.seed/once.yml b/app/.seed/once.yml\nnew file mode 100644\n--- /dev/null\n+++ b/app/.seed/once.yml\n",
+		"diff --git a/app/pom.xml b/app/pom.xml\n--- a/app/pom.xml\n+++ b/app/pom.xml\n",
+	} {
+		if !strings.Contains(string(dat), expected) {
+			t.Errorf("The patch should contain %q but found:\n%s", expected, dat)
+		}
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "app", "pom.xml")); string(data) != "<project>\n<version>1</version>\n</project>\n" {
+		t.Errorf("The files shouldn't be modified but found %q", data)
+	}
+
+	patchFile := filepath.Join(dir, "changes.patch")
+	if err = ioutil.WriteFile(patchFile, dat, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = git(dir, "apply", patchFile); err != nil {
+		t.Fatalf("The patch should be applied by git but found %v", err)
+	}
+	for path, expected := range map[string]string{
+		"app/src/App.java":   "import jakarta.inject;\nimport jakarta.ws;\n",
+		"app/NOTICE":         "Notice\nCopyright\n",
+		"app/.seed/once.yml": "NOTICE\n",
+	} {
+		if data, _ := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path))); !strings.Contains(string(data), expected) {
+			t.Errorf("%s should contain %q once patched but found %q", path, expected, data)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var reportPath string
+var sarifPath string
+var junitPath string
+
+// runReport collects the details of the files of the run when -report is
+// passed.
+var runReport *transform.Report
+
+// report is the JSON report of a run written with -report.
+type report struct {
+	// Tdf is the transformation file, or the chain directory, of the run
+	Tdf        string                 `json:"tdf"`
+	Directory  string                 `json:"directory"`
+	Command    string                 `json:"command"`
+	Started    string                 `json:"started"`
+	DurationMs float64                `json:"durationMs"`
+	Scanned    int                    `json:"scanned"`
+	Matched    int                    `json:"matched"`
+	Changed    int                    `json:"changed"`
+	Errors     []string               `json:"errors"`
+	Files      []transform.FileReport `json:"files"`
+}
+
+// startReport starts collecting the report of the run if it is requested,
+// in JSON, SARIF or JUnit, or to print the statistics.
+func startReport() {
+	if reportPath != "" || sarifPath != "" || junitPath != "" || showStats {
+		runReport = &transform.Report{}
+	}
+}
+
+// saveReport writes the requested reports of the run of the transformations,
+// with the number of scanned and changed files. The reports are not
+// journaled, they are kept by a rollback.
+func saveReport(start time.Time, changed, scanned int, transformations []transform.Transformation) {
+	if runReport == nil {
+		return
+	}
+	dir, err := filepath.Abs(dirPath)
+	if err != nil {
+		dir = dirPath
+	}
+	r := report{
+		Tdf:        transPath,
+		Directory:  dir,
+		Command:    command(),
+		Started:    start.Format(time.RFC3339),
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+		Scanned:    scanned,
+		Changed:    changed,
+		Errors:     []string{},
+		Files:      runReport.Files(),
+	}
+	for _, err := range runErrors {
+		r.Errors = append(r.Errors, err.Error())
+	}
+	r.Matched = len(r.Files)
+	// The paths are relative to the directory to aggregate the reports of
+	// several projects
+	for i, f := range r.Files {
+		if abs, err := filepath.Abs(f.File); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				r.Files[i].File = filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	if reportPath != "" {
+		writeReport(reportPath, "JSON", func() ([]byte, error) { return json.MarshalIndent(r, "", "  ") })
+	}
+	if sarifPath != "" {
+		writeReport(sarifPath, "SARIF", func() ([]byte, error) { return sarifReport(r, transformations) })
+	}
+	if junitPath != "" {
+		writeReport(junitPath, "JUnit", func() ([]byte, error) { return junitReport(r, transformations) })
+	}
+}
+
+func writeReport(path, format string, marshal func() ([]byte, error)) {
+	dat, err := marshal()
+	if err == nil {
+		err = ioutil.WriteFile(path, append(dat, '\n'), 0644)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write the %s report of the run: %s", format, err)
+	}
+}
+
+// ruleIDs returns the identifiers of the transformations in the SARIF and
+// JUnit reports, with their name or their position in the file declaring
+// them, like in the validation errors.
+func ruleIDs(transformations []transform.Transformation) []string {
+	positions := make(map[string]int)
+	var ids []string
+	for _, transf := range transformations {
+		source := transf.Source
+		if source == "" {
+			source = transPath
+		}
+		positions[source]++
+		if transf.Name != "" {
+			ids = append(ids, fmt.Sprintf("%s#%s", filepath.Base(source), transf.Name))
+		} else {
+			ids = append(ids, fmt.Sprintf("%s#%v", filepath.Base(source), positions[source]))
+		}
+	}
+	return ids
+}
+
+// linesText formats the changed lines of a transformation.
+func linesText(changes []transform.LineRange) string {
+	var ranges []string
+	for _, c := range changes {
+		if c.Start == c.End {
+			ranges = append(ranges, fmt.Sprintf("%v", c.Start))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%v-%v", c.Start, c.End))
+		}
+	}
+	if len(ranges) == 1 && !strings.Contains(ranges[0], "-") {
+		return "line " + ranges[0]
+	}
+	return "lines " + strings.Join(ranges, ", ")
+}
+
+// command returns the command of the run as named in the report.
+func command() string {
+	switch {
+	case checkOnly:
+		return "check"
+	case verifyIdempotent:
+		return "verify-idempotent"
+	default:
+		return "fix"
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/seedstack/tools/transform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seed-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reportPath = filepath.Join(dir, "report.json")
+	runErrors = []error{errors.New("b.txt: transformation 1: Replace: failed")}
+	startReport()
+	defer func() { reportPath, runReport, runErrors = "", nil, nil }()
+
+	tr := transform.T{Transformations: []transform.Transformation{transform.Transformation{
+		Filter: "*.txt",
+		Proc:   []transform.Procedure{transform.Procedure{Name: "Insert", Params: []string{"!"}}},
+	}}}
+	transform.Apply("a.txt", []byte("a"), tr, options())
+	saveReport(time.Now(), 1, 3, tr.Transformations)
+
+	dat, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err = json.Unmarshal(dat, &r); err != nil {
+		t.Fatalf("The report should be valid JSON but found %v", err)
+	}
+	if r.Command != "fix" || r.Scanned != 3 || r.Matched != 1 || r.Changed != 1 || len(r.Errors) != 1 {
+		t.Errorf("The report should summarize the run but found %+v", r)
+	}
+	if len(r.Files) != 1 || r.Files[0].File != "a.txt" || r.Files[0].BytesAfter != 2 {
+		t.Errorf("The report should detail the files but found %+v", r.Files)
+	}
+}
+
+func TestRuleIDs(t *testing.T) {
+	ids := ruleIDs([]transform.Transformation{
+		transform.Transformation{Source: "maven.yml"},
+		transform.Transformation{Source: "tdf.yml", Name: "license"},
+		transform.Transformation{Source: "tdf.yml"},
+	})
+	if !reflect.DeepEqual(ids, []string{"maven.yml#1", "tdf.yml#license", "tdf.yml#2"}) {
+		t.Errorf("The transformations should be identified by their name or position but found %v", ids)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/seedstack/tools/transform"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// The subset of SARIF 2.1.0 written by seed check -sarif.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription sarifMessage  `json:"shortDescription"`
+	FullDescription  *sarifMessage `json:"fullDescription,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// sarifReport returns the SARIF log of a check run. Each transformation is
+// a rule, and each file it would modify is a result located at the lines
+// it would change. The errors of the run are notifications.
+func sarifReport(r report, transformations []transform.Transformation) ([]byte, error) {
+	ids := ruleIDs(transformations)
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "seed",
+			InformationURI: "https://github.com/seedstack/tools",
+			Rules:          []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: len(r.Errors) == 0}},
+		Results:     []sarifResult{},
+	}
+	for i, transf := range transformations {
+		rule := sarifRule{ID: ids[i], ShortDescription: sarifMessage{describe(transf)}}
+		if transf.Description != "" {
+			rule.FullDescription = &sarifMessage{transf.Description}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	for _, msg := range r.Errors {
+		run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications,
+			sarifNotification{Level: "error", Message: sarifMessage{msg}})
+	}
+
+	for _, f := range r.Files {
+		for _, tr := range f.Transformations {
+			if len(tr.Changes) == 0 || tr.Index > len(transformations) {
+				continue
+			}
+			res := sarifResult{
+				RuleID:    ids[tr.Index-1],
+				RuleIndex: tr.Index - 1,
+				Level:     "warning",
+				Message: sarifMessage{fmt.Sprintf("%s should be fixed by transformation %s (%s) at %s",
+					f.File, ids[tr.Index-1], describe(transformations[tr.Index-1]), linesText(tr.Changes))},
+			}
+			for _, c := range tr.Changes {
+				res.Locations = append(res.Locations, sarifLocation{sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: f.File},
+					Region:           &sarifRegion{StartLine: c.Start, EndLine: c.End},
+				}})
+			}
+			run.Results = append(run.Results, res)
+		}
+	}
+	return json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+}
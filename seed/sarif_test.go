@@ -0,0 +1,87 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/seedstack/tools/transform"
+	"reflect"
+	"testing"
+)
+
+// checkedRun returns the report of a check run with a file to fix and a
+// failing file, and its transformations.
+func checkedRun() (report, []transform.Transformation) {
+	transformations := []transform.Transformation{
+		transform.Transformation{Filter: "pom.xml", Source: "maven.yml",
+			Proc: []transform.Procedure{transform.Procedure{Name: "ReplaceMavenDependency"}}},
+		transform.Transformation{Filter: "*.java", Source: "tdf.yml",
+			Proc: []transform.Procedure{transform.Procedure{Name: "Replace"}, transform.Procedure{Name: "Insert"}}},
+		transform.Transformation{Filter: "*.java", Source: "tdf.yml",
+			Proc: []transform.Procedure{transform.Procedure{Name: "Plugin"}}},
+	}
+	r := report{
+		DurationMs: 1500,
+		Errors:     []string{"src/Bad.java: transformation 2: Plugin: failed"},
+		Files: []transform.FileReport{
+			transform.FileReport{File: "src/App.java", DurationMs: 10, Transformations: []*transform.TransformationReport{
+				&transform.TransformationReport{Index: 2, Applied: true, DurationMs: 6,
+					Changes: []transform.LineRange{{Start: 1, End: 1}, {Start: 4, End: 6}}},
+				&transform.TransformationReport{Index: 3, DurationMs: 2},
+			}},
+			transform.FileReport{File: "src/Bad.java", DurationMs: 20, Transformations: []*transform.TransformationReport{
+				&transform.TransformationReport{Index: 2, DurationMs: 4},
+				&transform.TransformationReport{Index: 3, DurationMs: 12, Error: "Plugin: failed"},
+			}},
+		},
+	}
+	return r, transformations
+}
+
+func TestSarifReport(t *testing.T) {
+	dat, err := sarifReport(checkedRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err = json.Unmarshal(dat, &log); err != nil {
+		t.Fatalf("The SARIF log should be valid JSON but found %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("The SARIF log should have a run but found %s", dat)
+	}
+	run := log.Runs[0]
+
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID+" "+rule.ShortDescription.Text)
+	}
+	expectedRules := []string{"maven.yml#1 pom.xml: ReplaceMavenDependency", "tdf.yml#1 *.java: Replace, Insert", "tdf.yml#2 *.java: Plugin"}
+	if !reflect.DeepEqual(rules, expectedRules) {
+		t.Errorf("The transformations should be the rules %q but found %q", expectedRules, rules)
+	}
+
+	if len(run.Results) != 1 {
+		t.Fatalf("Only App.java should be reported but found %v results", len(run.Results))
+	}
+	res := run.Results[0]
+	expectedMsg := "src/App.java should be fixed by transformation tdf.yml#1 (*.java: Replace, Insert) at lines 1, 4-6"
+	if res.RuleID != "tdf.yml#1" || res.RuleIndex != 1 || res.Message.Text != expectedMsg {
+		t.Errorf("The result should be the App.java fix but found %+v", res)
+	}
+	if len(res.Locations) != 2 || res.Locations[1].PhysicalLocation.ArtifactLocation.URI != "src/App.java" ||
+		*res.Locations[1].PhysicalLocation.Region != (sarifRegion{4, 6}) {
+		t.Errorf("The result should be located at the changed lines but found %+v", res.Locations)
+	}
+
+	inv := run.Invocations[0]
+	if inv.ExecutionSuccessful || len(inv.ToolExecutionNotifications) != 1 {
+		t.Errorf("The errors of the run should be notifications but found %+v", inv)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"github.com/seedstack/tools/transform"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+var showStats bool
+
+// transformationStats are the statistics of a transformation over a run.
+type transformationStats struct {
+	matched, applied, changed, replacements int
+	duration                                time.Duration
+}
+
+// computeStats aggregates the reports of the files by transformation. The
+// transformations are identified by their source and their position, so
+// the files of previous transformation files of a chain are ignored.
+func computeStats(transformations []transform.Transformation, files []transform.FileReport) []transformationStats {
+	type key struct {
+		source string
+		index  int
+	}
+	positions := make(map[key]int)
+	for i, transf := range transformations {
+		positions[key{transf.Source, i + 1}] = i
+	}
+
+	stats := make([]transformationStats, len(transformations))
+	for _, f := range files {
+		for _, tr := range f.Transformations {
+			i, ok := positions[key{tr.Source, tr.Index}]
+			if !ok {
+				continue
+			}
+			s := &stats[i]
+			s.matched++
+			if tr.Applied {
+				s.applied++
+			}
+			if len(tr.Changes) > 0 {
+				s.changed++
+			}
+			s.replacements += tr.Replacements
+			s.duration += time.Duration(tr.DurationMs * float64(time.Millisecond))
+		}
+	}
+	return stats
+}
+
+// printStats prints a table of the statistics of the transformations, to
+// find the transformations which never match or which are slow. The
+// transformations skipped with -only or -skip are not listed.
+func printStats(w io.Writer, transformations []transform.Transformation, files []transform.FileReport) {
+	ids := ruleIDs(transformations)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Transformation\tMatched\tApplied\tChanged\tReplacements\tTime")
+	opts := options()
+	for i, s := range computeStats(transformations, files) {
+		if !opts.Selects(transformations[i]) {
+			continue
+		}
+		fmt.Fprintf(tw, "%s %s\t%v\t%v\t%v\t%v\t%.2fms\n", ids[i], describe(transformations[i]),
+			s.matched, s.applied, s.changed, s.replacements, float64(s.duration)/float64(time.Millisecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"github.com/seedstack/tools/transform"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tr := transform.T{Transformations: []transform.Transformation{
+		transform.Transformation{Filter: "*.java", Source: "tdf.yml", Pre: []string{`Script("javax" in content)`},
+			Proc: []transform.Procedure{transform.Procedure{Name: "Replace", Params: []string{"javax", "jakarta"}}}},
+		transform.Transformation{Filter: "*.xml", Source: "tdf.yml",
+			Proc: []transform.Procedure{transform.Procedure{Name: "Insert", Params: []string{"\n"}}}},
+	}}
+	report := &transform.Report{}
+	opts := transform.Options{Report: report}
+	transform.Apply("App.java", []byte("import javax.a;\nimport javax.b;\n"), tr, opts)
+	transform.Apply("Util.java", []byte("import javax.c;\n"), tr, opts)
+	transform.Apply("Old.java", []byte("import java.util;\n"), tr, opts)
+	// A file of another transformation file of a chain
+	other := tr
+	other.Transformations = []transform.Transformation{tr.Transformations[0]}
+	other.Transformations[0].Source = "002-next.yml"
+	transform.Apply("Next.java", []byte("import javax.d;\n"), other, opts)
+
+	stats := computeStats(tr.Transformations, report.Files())
+	if s := stats[0]; s.matched != 3 || s.applied != 2 || s.changed != 2 || s.replacements != 3 {
+		t.Errorf("The java transformation should have matched 3 files and changed 2 but found %+v", s)
+	}
+	if s := stats[1]; s.matched != 0 || s.replacements != 0 {
+		t.Errorf("The dead xml transformation should match nothing but found %+v", s)
+	}
+
+	var out bytes.Buffer
+	printStats(&out, tr.Transformations, report.Files())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(strings.TrimSpace(lines[0]), "Transformation") {
+		t.Fatalf("The table should have a header and a line per transformation but found:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 8 || fields[0] != "tdf.yml#2" || fields[3] != "0" {
+		t.Errorf("The xml transformation should be listed with its statistics but found %q", lines[2])
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// varFlags collects the "key=value" pairs passed with the -var flag.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	var pairs []string
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varFlags) Set(s string) error {
+	index := strings.Index(s, "=")
+	if index <= 0 {
+		return fmt.Errorf(`expected "key=value" but found "%s"`, s)
+	}
+	v[s[:index]] = s[index+1:]
+	return nil
+}
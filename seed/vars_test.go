@@ -0,0 +1,26 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestVarFlags(t *testing.T) {
+	v := make(varFlags)
+	if err := v.Set("version=16.4=final"); err != nil || v["version"] != "16.4=final" {
+		t.Errorf("version=16.4=final should be parsed but found %v, %v", v, err)
+	}
+	if err := v.Set("version"); err == nil {
+		t.Error("A variable without value should be rejected")
+	}
+	if err := v.Set("=16.4"); err == nil {
+		t.Error("A variable without name should be rejected")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PostCommand is a command run once after the transformations, with the
+// modified files as additional arguments.
+type PostCommand struct {
+	// Filter selects the modified files passed to the command, all the
+	// modified files are passed if it is empty
+	Filter  string   `yaml:"filter,omitempty" toml:"filter,omitempty" json:"filter,omitempty"`
+	Command []string `yaml:"command" toml:"command" json:"command"`
+	// Source is the transformation file declaring the command
+	Source string `yaml:"-" toml:"-" json:"-"`
+}
+
+func init() {
+	RegisterProcedure(ProcedureDef{
+		Name: "Command",
+		Description: "Pipes the content of the file through an external command, typically a formatter, " +
+			"and replaces it with the standard output of the command. The path of the file is available " +
+			"in the SEED_FILE environment variable of the command. It is stopped after the timeout set " +
+			"with -timeout.",
+		Params: []Param{
+			{Name: "command", Description: "the path of the executable, or its name in the PATH"},
+			{Name: "args", Description: "the arguments passed to the executable", Variadic: true},
+		},
+		Examples: []string{"proc:\n  - name: Command\n    params: [\"gofmt\", \"-s\"]"},
+		Validate: func(params []string) error {
+			return lookCommand(params[0])
+		},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			stdout, stderr, err := execute(params, data, []string{"SEED_FILE=" + env.File}, env.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			if env.Out != nil && len(stderr) > 0 {
+				env.Out.Write(stderr)
+			}
+			return stdout, nil
+		},
+	})
+}
+
+func lookCommand(command string) error {
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("can't run %s: %s", command, err)
+	}
+	return nil
+}
+
+// execute runs the command with the input on its standard input and
+// returns its outputs. The variables are added to the environment of the
+// command, which is stopped after the timeout if it is positive.
+func execute(command []string, input []byte, vars []string, timeout time.Duration) ([]byte, []byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if len(vars) > 0 {
+		cmd.Env = append(os.Environ(), vars...)
+	}
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, nil, fmt.Errorf("%s timed out after %s", command[0], timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, nil, fmt.Errorf("%s failed: %s: %s", command[0], err, msg)
+		}
+		return nil, nil, fmt.Errorf("%s failed: %s", command[0], err)
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// RunPost runs each post command of the transformations once, with the
+// modified files matching its filter. The commands without matching files
+// are not run. Their output is written to opts.Out in verbose mode.
+func RunPost(t T, files []string, opts Options) []error {
+	var errs []error
+	for _, post := range t.Post {
+		var args []string
+		for _, f := range files {
+			ok := post.Filter == ""
+			if !ok {
+				var err error
+				if ok, err = checkFileName(f, Transformation{Filter: post.Filter}); err != nil {
+					return append(errs, err)
+				}
+			}
+			if ok {
+				args = append(args, f)
+			}
+		}
+		if len(args) == 0 || len(post.Command) == 0 {
+			continue
+		}
+
+		if opts.verbose() {
+			fmt.Fprintf(opts.out(), "Run %s on %v files\n", strings.Join(post.Command, " "), len(args))
+		}
+		stdout, stderr, err := execute(append(append([]string{}, post.Command...), args...), nil, nil, opts.Timeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("post command %s", err))
+			continue
+		}
+		if opts.verbose() {
+			opts.out().Write(stdout)
+			opts.out().Write(stderr)
+		}
+	}
+	return errs
+}
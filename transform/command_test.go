@@ -0,0 +1,124 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestCommand is run by TestMain for the command-* modes.
+func runTestCommand(mode string) {
+	switch mode {
+	case "command-upper":
+		data, _ := ioutil.ReadAll(os.Stdin)
+		os.Stdout.Write(bytes.ToUpper(data))
+		fmt.Fprintf(os.Stderr, "formatted %s\n", os.Getenv("SEED_FILE"))
+	case "command-fail":
+		fmt.Fprintln(os.Stderr, "syntax error")
+		os.Exit(3)
+	case "command-mark":
+		for _, f := range os.Args[1:] {
+			data, _ := ioutil.ReadFile(f)
+			ioutil.WriteFile(f, append(data, "!"...), 0644)
+		}
+	}
+}
+
+func applyCommand(mode string) (string, string, error) {
+	os.Setenv("SEED_TEST_PLUGIN", mode)
+	defer os.Unsetenv("SEED_TEST_PLUGIN")
+
+	p := []Procedure{Procedure{Name: "Command", Params: []string{os.Args[0]}}}
+	tr := T{Transformations: []Transformation{Transformation{Filter: "*.java", Proc: p}}}
+	var out bytes.Buffer
+	res, err := Apply("App.java", []byte("class app"), tr, Options{VeryVerbose: true, Out: &out, Timeout: time.Minute})
+	return string(res), out.String(), err
+}
+
+func TestCommand(t *testing.T) {
+	res, out, err := applyCommand("command-upper")
+	if res != "CLASS APP" || err != nil {
+		t.Errorf("The content should be piped through the command but found %q, %v", res, err)
+	}
+	if !strings.Contains(out, "formatted App.java") {
+		t.Errorf("The command should receive the path of the file and its messages should be shown but found %q", out)
+	}
+
+	expected := "failed: exit status 3: syntax error"
+	if _, _, err = applyCommand("command-fail"); err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("The command should fail with %q but found %v", expected, err)
+	}
+}
+
+func TestRunPost(t *testing.T) {
+	os.Setenv("SEED_TEST_PLUGIN", "command-mark")
+	defer os.Unsetenv("SEED_TEST_PLUGIN")
+
+	dir, err := ioutil.TempDir("", "seed-post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, name := range []string{"App.java", "pom.xml"} {
+		files = append(files, filepath.Join(dir, name))
+		ioutil.WriteFile(files[len(files)-1], []byte(name), 0644)
+	}
+
+	tr := T{Post: []PostCommand{
+		PostCommand{Filter: "*.java", Command: []string{os.Args[0]}},
+		PostCommand{Filter: "*.txt", Command: []string{"./missing-command"}},
+	}}
+	if errs := RunPost(tr, files, Options{Timeout: time.Minute}); len(errs) > 0 {
+		t.Fatalf("The post commands should succeed but found %v", errs)
+	}
+	for _, c := range []struct{ file, expected string }{{files[0], "App.java!"}, {files[1], "pom.xml"}} {
+		if data, _ := ioutil.ReadFile(c.file); string(data) != c.expected {
+			t.Errorf("%s should contain %q but found %q", c.file, c.expected, data)
+		}
+	}
+
+	tr.Post = []PostCommand{PostCommand{Command: []string{"./missing-command"}}}
+	if errs := RunPost(tr, files, Options{}); len(errs) != 1 || !strings.Contains(errs[0].Error(), "missing-command") {
+		t.Errorf("The failing post command should be reported but found %v", errs)
+	}
+}
+
+func TestValidatePost(t *testing.T) {
+	tr := T{Post: []PostCommand{
+		PostCommand{Filter: "*.java", Command: []string{os.Args[0]}},
+		PostCommand{Filter: "[", Command: []string{"./missing-command"}},
+		PostCommand{},
+	}}
+	errs := Validate(tr, "tdf.yml")
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	expected := []string{
+		"tdf.yml: post command 2: can't run ./missing-command",
+		"tdf.yml: post command 2: invalid filter [",
+		"tdf.yml: post command 3: missing command",
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("Expected %v errors but found %q", len(expected), msgs)
+	}
+	for i, msg := range msgs {
+		if !strings.HasPrefix(msg, expected[i]) {
+			t.Errorf("Expected %q but found %q", expected[i], msg)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around the changes of a hunk.
+const diffContext = 3
+
+// diffOp is a line of a diff. Its kind is ' ' for an unchanged line,
+// '-' for a deleted line and '+' for an inserted line.
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// hunk is a group of close changes with their context. The start lines
+// begin at 1, like in a unified diff.
+type hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Ops                []diffOp
+	// first and last are the positions of Ops in the edit script
+	first, last int
+}
+
+// splitLines splits the data in lines, keeping the end of lines so the
+// data can be rebuilt from the lines.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script transforming a into b,
+// computed with the linear space variant of the Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	return myers(nil, a, b)
+}
+
+// myers appends to ops the edit script transforming a into b. The problem
+// is split in two at a point of the shortest path found by searching from
+// both ends, so the memory stays linear in the number of lines.
+func myers(ops []diffOp, a, b []string) []diffOp {
+	// Skip the common prefix and suffix to reduce the size of the problem
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(midA) == 0:
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	case len(midB) == 0:
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+	default:
+		if x, y, ok := middle(midA, midB); ok {
+			ops = myers(ops, midA[:x], midB[:y])
+			ops = myers(ops, midA[x:], midB[y:])
+		} else {
+			for _, line := range midA {
+				ops = append(ops, diffOp{'-', line})
+			}
+			for _, line := range midB {
+				ops = append(ops, diffOp{'+', line})
+			}
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// middle finds the point where the paths searched forward from the start
+// and backward from the end of the edit graph of a and b overlap. The
+// paths leaving the graph are not extended.
+func middle(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*maxD+3)
+	backward := make([]int, 2*maxD+3)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta the forward path overlaps the backward one first
+	front := delta%2 != 0
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && x >= n-backward[j] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				x = backward[i+1]
+			} else {
+				x = backward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[i] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					if fx >= n-x {
+						return fx, fx - (j - offset), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// diffHunks groups the changes of the edit script in hunks, with the
+// given number of unchanged lines around them.
+func diffHunks(ops []diffOp, context int) []hunk {
+	var hunks []hunk
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Find the last change close enough to be in the same hunk
+		end := i
+		for j := i; j < len(ops) && j <= end+2*context+1; j++ {
+			if ops[j].Kind != ' ' {
+				end = j
+			}
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		stop := end + context + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		h := hunk{OldStart: oldLine - (i - start), NewStart: newLine - (i - start), Ops: ops[start:stop],
+			first: start, last: stop - 1}
+		for _, op := range h.Ops {
+			if op.Kind != '+' {
+				h.OldLines++
+			}
+			if op.Kind != '-' {
+				h.NewLines++
+			}
+		}
+		hunks = append(hunks, h)
+
+		for _, op := range ops[i:stop] {
+			if op.Kind != '+' {
+				oldLine++
+			}
+			if op.Kind != '-' {
+				newLine++
+			}
+		}
+		i = stop
+	}
+	return hunks
+}
+
+// String formats the hunk like in a unified diff.
+func (h hunk) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	for _, op := range h.Ops {
+		buf.WriteByte(op.Kind)
+		buf.WriteString(op.Line)
+		if !strings.HasSuffix(op.Line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return buf.String()
+}
+
+func hunkRange(start, lines int) string {
+	// An empty range starts at the line before the change
+	if lines == 0 {
+		return fmt.Sprintf("%v,0", start-1)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%v", start)
+	}
+	return fmt.Sprintf("%v,%v", start, lines)
+}
+
+// UnifiedDiff returns the differences between the old and the new data in
+// the unified format, or an empty string if they are equal.
+func UnifiedDiff(oldName, newName string, oldData, newData []byte) string {
+	hunks := diffHunks(diffLines(splitLines(oldData), splitLines(newData)), diffContext)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		buf.WriteString(h.String())
+	}
+	return buf.String()
+}
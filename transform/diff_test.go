@@ -0,0 +1,207 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	oldData := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	newData := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"
+	expected := `--- a/file
++++ b/file
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+\ No newline at end of file
+`
+	if res := UnifiedDiff("a/file", "b/file", []byte(oldData), []byte(newData)); res != expected {
+		t.Errorf("unifiedDiff: expected\n%s\nbut found\n%s", expected, res)
+	}
+
+	if res := UnifiedDiff("a/file", "b/file", []byte(oldData), []byte(oldData)); res != "" {
+		t.Errorf("unifiedDiff: no difference was expected but found\n%s", res)
+	}
+
+	expected = "--- a/file\n+++ b/file\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if res := UnifiedDiff("a/file", "b/file", nil, []byte("x\ny\n")); res != expected {
+		t.Errorf("unifiedDiff: expected\n%s\nbut found\n%s", expected, res)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	ops := diffLines(strings.Split("abcabba", ""), strings.Split("cbabac", ""))
+	var a, b string
+	changes := 0
+	for _, op := range ops {
+		if op.Kind != '+' {
+			a += op.Line
+		}
+		if op.Kind != '-' {
+			b += op.Line
+		}
+		if op.Kind != ' ' {
+			changes++
+		}
+	}
+	if a != "abcabba" || b != "cbabac" || changes != 5 {
+		t.Errorf("diffLines should find the shortest edit script but found %v", ops)
+	}
+}
+
+// lcsChanges returns the number of changes of the shortest edit script,
+// from the length of the longest common subsequence.
+func lcsChanges(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] > lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 500; i++ {
+		a := strings.Split(randomText(r, r.Intn(30)), "")
+		b := strings.Split(randomText(r, r.Intn(30)), "")
+		changes := 0
+		var resA, resB string
+		for _, op := range diffLines(a, b) {
+			if op.Kind != ' ' {
+				changes++
+			}
+			if op.Kind != '+' {
+				resA += op.Line
+			}
+			if op.Kind != '-' {
+				resB += op.Line
+			}
+		}
+		if resA != strings.Join(a, "") || resB != strings.Join(b, "") || changes != lcsChanges(a, b) {
+			t.Fatalf("The edit script of %v and %v should have %v changes but found %v", a, b, lcsChanges(a, b), changes)
+		}
+	}
+}
+
+func randomText(r *rand.Rand, length int) string {
+	var buf []byte
+	for i := 0; i < length; i++ {
+		buf = append(buf, "abc"[r.Intn(3)])
+	}
+	return string(buf)
+}
+
+// TestDiffLargeFile checks that a file whose lines all change is diffed in
+// linear memory, like the end of lines of a Windows file.
+func TestDiffLargeFile(t *testing.T) {
+	var crlf, lf []string
+	for i := 0; i < 5000; i++ {
+		line := strings.Repeat("x", i%80)
+		crlf = append(crlf, line+"\r\n")
+		lf = append(lf, line+"\n")
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := diffLines(crlf, lf)
+	runtime.ReadMemStats(&after)
+
+	changes := 0
+	for _, op := range ops {
+		if op.Kind != ' ' {
+			changes++
+		}
+	}
+	if changes != 10000 {
+		t.Errorf("All the lines should be replaced but found %v changes", changes)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 32<<20 {
+		t.Errorf("The diff should allocate little memory but allocated %v bytes", allocated)
+	}
+}
+
+// TestUnifiedDiffWithGitApply checks that random diffs can be applied by git.
+func TestUnifiedDiffWithGitApply(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "seed-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := rand.New(rand.NewSource(42))
+	words := []string{"foo\n", "bar\n", "baz\n", "qux\n", "seed\n"}
+	for i := 0; i < 50; i++ {
+		var oldLines, newLines []string
+		for j := 0; j < r.Intn(40); j++ {
+			line := words[r.Intn(len(words))]
+			oldLines = append(oldLines, line)
+			switch r.Intn(6) {
+			case 0:
+			case 1:
+				newLines = append(newLines, words[r.Intn(len(words))])
+			case 2:
+				newLines = append(newLines, line, words[r.Intn(len(words))])
+			default:
+				newLines = append(newLines, line)
+			}
+		}
+		oldData, newData := strings.Join(oldLines, ""), strings.Join(newLines, "")
+		diff := UnifiedDiff("a/file", "b/file", []byte(oldData), []byte(newData))
+		if diff == "" {
+			continue
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(dir, "file"), []byte(oldData), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, "file.patch"), []byte(diff), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("git", "apply", "--unidiff-zero", "file.patch")
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git apply failed on:\n%s\n%v: %s", diff, err, out)
+		}
+		if res := readContent(t, filepath.Join(dir, "file")); res != newData {
+			t.Fatalf("The patch should transform\n%s\ninto\n%s\nbut found\n%s", oldData, newData, res)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+/*
+
+Package transform is the engine of the seed command. It loads the
+transformation description files, walks the directories to transform
+and applies the transformations on their files.
+
+Usage
+
+Load a transformation file, check it, then fix a directory:
+
+        t, err := transform.Load("tdf.yml")
+        if err != nil {
+                return err
+        }
+        if errs := transform.Validate(t, "tdf.yml"); len(errs) > 0 {
+                return errs[0]
+        }
+        opts := transform.Options{Jobs: 4, GitIgnore: true}
+        files, errs := transform.Walk("./myproject", t.Exclude, "tdf.yml", opts)
+        res := transform.Fix(files, t, opts)
+
+Use Check to list the files to fix without writing them, and Apply to
+transform a content in memory. New procedures and preconditions are made
+available to the transformation files with RegisterProcedure and
+RegisterPrecondition.
+
+*/
+package transform
@@ -0,0 +1,85 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"fmt"
+)
+
+// TransformError is a failure to transform a file. The file is left
+// unmodified and the other files are still transformed.
+type TransformError struct {
+	// File is the path of the file which failed
+	File string
+	// Source is the transformation file declaring the failing
+	// transformation, if it was read by Load
+	Source string
+	// Transformation is the position of the failing transformation in its
+	// file, starting at 1, or 0 if the failure is not related to a
+	// transformation
+	Transformation int
+	// Name is the name of the failing transformation, if it has one
+	Name string
+	// Procedure is the name of the failing procedure or precondition
+	Procedure string
+	Err       error
+
+	// index is the position of the transformation in the list, includes
+	// first, like in the reports
+	index int
+}
+
+func (e *TransformError) Error() string {
+	msg := ShortPath(e.File)
+	if e.Source != "" {
+		msg += ": " + e.Source
+	}
+	msg += transformationName(e.Transformation, e.Name)
+	if e.Procedure != "" {
+		msg += fmt.Sprintf(": %s", e.Procedure)
+	}
+	return fmt.Sprintf("%s: %s", msg, e.Err)
+}
+
+// Unwrap returns the cause of the failure, for errors.Is and errors.As.
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// withLocation returns the error with the file and the transformation, at
+// the index i of the list, where it happened. The transformation is located
+// in the file declaring it.
+func withLocation(err error, file string, t T, i int) *TransformError {
+	transf := t.Transformations[i]
+	position := 1
+	for _, previous := range t.Transformations[:i] {
+		if previous.Source == transf.Source {
+			position++
+		}
+	}
+	located := &TransformError{Err: err}
+	if e, ok := err.(*TransformError); ok {
+		*located = *e
+	}
+	located.File, located.Source, located.Transformation, located.Name = file, transf.Source, position, transf.Name
+	located.index = i + 1
+	return located
+}
+
+// transformationName locates a message in a transformation, named after
+// its position and its name if it has one.
+func transformationName(position int, name string) string {
+	switch {
+	case position > 0 && name != "":
+		return fmt.Sprintf(": transformation %v (%s)", position, name)
+	case position > 0:
+		return fmt.Sprintf(": transformation %v", position)
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const onceStateFile = "once.yml"
+
+// OnceState records for each transformation marked as "once" the files
+// of a project on which it was applied.
+type OnceState struct {
+	mu      sync.Mutex
+	root    string
+	applied map[string]map[string]bool
+}
+
+// NewOnceState returns an empty state for the project at root.
+func NewOnceState(root string) *OnceState {
+	return &OnceState{root: root, applied: make(map[string]map[string]bool)}
+}
+
+// onceKey identifies a transformation by its content, so the key is stable
+// when other transformations are added to the transformation file.
+func onceKey(t Transformation) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\n%q\n", t.Filter, t.Pre)
+	for _, proc := range t.Proc {
+		fmt.Fprintf(h, "%s%q\n", proc.Name, proc.Params)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+func (s *OnceState) relPath(filePath string) string {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	relPath, err := filepath.Rel(s.root, absPath)
+	if err != nil {
+		return filepath.ToSlash(absPath)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+func (s *OnceState) has(t Transformation, filePath string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied[onceKey(t)][s.relPath(filePath)]
+}
+
+func (s *OnceState) add(t Transformation, filePath string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := onceKey(t)
+	if s.applied[key] == nil {
+		s.applied[key] = make(map[string]bool)
+	}
+	s.applied[key][s.relPath(filePath)] = true
+}
+
+// Copy returns a copy of the state which can be modified without
+// affecting the original one.
+func (s *OnceState) Copy() *OnceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := NewOnceState(s.root)
+	for key, files := range s.applied {
+		res.applied[key] = make(map[string]bool)
+		for file := range files {
+			res.applied[key][file] = true
+		}
+	}
+	return res
+}
+
+// ReadOnceState reads the once markers recorded in the given project.
+func ReadOnceState(root string) (*OnceState, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	s := NewOnceState(absRoot)
+
+	dat, err := ioutil.ReadFile(filepath.Join(absRoot, StateDir, onceStateFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var applied map[string][]string
+	if err = yaml.Unmarshal(dat, &applied); err != nil {
+		return nil, err
+	}
+	for key, files := range applied {
+		s.applied[key] = make(map[string]bool)
+		for _, file := range files {
+			s.applied[key][file] = true
+		}
+	}
+	return s, nil
+}
+
+// Save writes the once markers in the project with writeFile, or
+// ioutil.WriteFile if it is nil. Only ioutil.WriteFile creates the state
+// directory, writeFile may not write in the project.
+func (s *OnceState) Save(writeFile func(path string, data []byte) error) error {
+	s.mu.Lock()
+	applied := make(map[string][]string)
+	for key, files := range s.applied {
+		for file := range files {
+			applied[key] = append(applied[key], file)
+		}
+		sort.Strings(applied[key])
+	}
+	s.mu.Unlock()
+
+	if len(applied) == 0 {
+		return nil
+	}
+	dat, err := yaml.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.root, StateDir, onceStateFile)
+	if writeFile != nil {
+		return writeFile(path, dat)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, dat, 0644)
+}
+
+// Verify runs the transformations twice in memory on each file without
+// writing them. It returns the files whose content is still modified by the
+// second pass, i.e. the files on which the transformations are not idempotent.
+func Verify(files []string, t T, opts Options) Result {
+	var res Result
+	res.Files, res.Errors = runFiles(files, opts, func(filePath string, out io.Writer) (bool, error) {
+		if opts.verbose() {
+			fmt.Fprintf(out, "Check file %s\n", ShortPath(filePath))
+		}
+
+		origDat, data, err := processFile(filePath, t, opts, out)
+		if err != nil || bytes.Equal(origDat, data) {
+			return false, err
+		}
+
+		// The second pass is not reported
+		again := opts
+		again.Report = nil
+		second, err := transformData(filePath, data, t, again, out)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(data, second), nil
+	})
+	return res
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnceKey(t *testing.T) {
+	insert := Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"foo"}}}}
+	other := Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"bar"}}}}
+	sourced := insert
+	sourced.Source = "other.yml"
+
+	if onceKey(insert) != onceKey(sourced) {
+		t.Error("The key should only depend on the content of the transformation")
+	}
+	if onceKey(insert) == onceKey(other) {
+		t.Error("Different transformations should have different keys")
+	}
+}
+
+func TestOnceState(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"src/file.txt": "foo"})
+	defer os.RemoveAll(dir)
+
+	transf := Transformation{Filter: "*.txt", Once: true}
+	filePath := filepath.Join(dir, "src", "file.txt")
+
+	state, err := ReadOnceState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.has(transf, filePath) {
+		t.Error("A new project should not have once markers")
+	}
+
+	state.add(transf, filePath)
+	if err = state.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = ReadOnceState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.has(transf, filePath) {
+		t.Error("The once marker should be saved in the project")
+	}
+	if state.applied[onceKey(transf)]["src/file.txt"] != true {
+		t.Errorf("The file should be recorded relatively to the project but found %v", state.applied)
+	}
+
+	var nilState *OnceState
+	if nilState.has(transf, filePath) {
+		t.Error("Once markers should be ignored when they are not tracked")
+	}
+}
+
+func TestTransformDataOnce(t *testing.T) {
+	p := []Procedure{Procedure{Name: "Insert", Params: []string{"bar"}}}
+	tr := T{Transformations: []Transformation{Transformation{Filter: "*.txt", Pre: []string{"AlwaysTrue"}, Proc: p, Once: true}}}
+
+	opts := Options{Once: NewOnceState(os.TempDir())}
+	first, err := Apply("file.txt", []byte("foo"), tr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Apply("file.txt", first, tr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != "foobar" || string(second) != "foobar" {
+		t.Errorf("The transformation should be applied once but found %s and %s", first, second)
+	}
+}
+
+func TestVerifyFiles(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"insert.txt": "foo", "ensure.txt": "foo", "once.txt": "foo"})
+	defer os.RemoveAll(dir)
+
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "insert.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"bar"}}}},
+		Transformation{Filter: "ensure.txt", Proc: []Procedure{Procedure{Name: "EnsureInsert", Params: []string{"bar"}}}},
+		Transformation{Filter: "once.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"bar"}}}, Once: true},
+	}}
+	files := []string{filepath.Join(dir, "insert.txt"), filepath.Join(dir, "ensure.txt"), filepath.Join(dir, "once.txt")}
+
+	run := Verify(files, tr, Options{Once: NewOnceState(dir)})
+	res, errs := run.Files, run.Errors
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(res) != 1 || res[0] != files[0] {
+		t.Errorf("Only insert.txt should not be idempotent but found %v", res)
+	}
+
+	if dat, _ := ioutil.ReadFile(files[0]); string(dat) != "foo" {
+		t.Errorf("The files should not be modified by the verification but found %s", dat)
+	}
+}
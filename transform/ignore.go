@@ -0,0 +1,334 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// seedIgnoreFile lists the files to skip with the same syntax as a
+// ".gitignore" file, but it is only used by seed.
+const seedIgnoreFile = ".seedignore"
+
+const gitIgnoreFile = ".gitignore"
+
+// ignoreRule is a pattern of an ignore file. The patterns follow the
+// ".gitignore" syntax and are relative to the directory of their file.
+type ignoreRule struct {
+	negate  bool
+	dirOnly bool
+	regexp  *regexp.Regexp
+}
+
+// ignoreMatcher tells which files are ignored under its root directory.
+// Like git, the last rule matching a file decides if it is ignored.
+type ignoreMatcher struct {
+	root  string
+	names []string
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher returns the matcher used to walk dir. The ".seedignore"
+// files are always used. If gitIgnore is true and dir is in a git work tree,
+// the ".gitignore" files, from the top of the work tree, and the
+// "info/exclude" file of the repository are also used.
+func newIgnoreMatcher(dir string, gitIgnore bool) (*ignoreMatcher, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ignoreMatcher{root: absDir, names: []string{seedIgnoreFile}}
+	top, ok := findGitTop(absDir)
+	if !gitIgnore || !ok {
+		return m, nil
+	}
+
+	m.root = top
+	m.names = []string{gitIgnoreFile, seedIgnoreFile}
+	// The repository itself, or the file locating it, is never part of
+	// the work tree
+	if rule, ok := parseIgnoreRule(".git", ""); ok {
+		m.rules = append(m.rules, rule)
+	}
+	repo, err := gitDir(top)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.addFile(filepath.Join(repo, "info", "exclude"), ""); err != nil {
+		return nil, err
+	}
+
+	// Load the ignore files of the directories above dir, the ones of
+	// dir and its sub-directories are loaded during the walk.
+	var parents []string
+	for parent := absDir; parent != top; {
+		parent = filepath.Dir(parent)
+		parents = append([]string{parent}, parents...)
+	}
+	for _, parent := range parents {
+		if err = m.loadDir(parent); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Unignored returns the files which are not skipped by the ignore files
+// when walking root, like with Walk. The files must be in root.
+func Unignored(root string, files []string, opts Options) ([]string, error) {
+	m, err := newIgnoreMatcher(root, opts.GitIgnore)
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]bool)
+	var res []string
+	for _, file := range files {
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		// The directories are checked and loaded from the root, like
+		// during the walk
+		var dirs []string
+		for dir := filepath.Dir(absPath); ; dir = filepath.Dir(dir) {
+			dirs = append([]string{dir}, dirs...)
+			if dir == absRoot || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		ignored := false
+		for _, dir := range dirs {
+			if ignored = m.ignored(dir, true); ignored {
+				break
+			}
+			if !loaded[dir] {
+				loaded[dir] = true
+				if err = m.loadDir(dir); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !ignored && !m.ignored(absPath, false) {
+			res = append(res, file)
+		}
+	}
+	return res, nil
+}
+
+// findGitTop returns the top directory of the git work tree containing dir.
+func findGitTop(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// gitDir returns the repository of the git work tree at top. In a linked
+// work tree or a submodule, ".git" is a file with the path of the
+// repository, and the files shared by the work trees are in the common
+// directory of the repository.
+func gitDir(top string) (string, error) {
+	dir := filepath.Join(top, ".git")
+	if info, err := os.Stat(dir); err != nil || info.IsDir() {
+		return dir, err
+	}
+	dat, err := ioutil.ReadFile(dir)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(dat))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("%s doesn't locate a git repository", dir)
+	}
+	dir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(top, dir)
+	}
+	if common, err := ioutil.ReadFile(filepath.Join(dir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(dir, commonDir)
+		}
+		dir = commonDir
+	}
+	return dir, nil
+}
+
+// loadDir adds the rules of the ignore files of the given directory.
+func (m *ignoreMatcher) loadDir(dir string) error {
+	base, err := m.rel(dir)
+	if err != nil {
+		return err
+	}
+	if base == "." {
+		base = ""
+	}
+	for _, name := range m.names {
+		if err = m.addFile(filepath.Join(dir, name), base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFile adds the rules of an ignore file, relative to the base directory.
+// A missing file is ignored.
+func (m *ignoreMatcher) addFile(path, base string) error {
+	dat, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text(), base); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+func (m *ignoreMatcher) rel(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.root, absPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ignored returns true if the file, or the directory, is ignored.
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	rel, err := m.rel(path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.regexp.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreRule parses a line of an ignore file. It returns false for
+// blank lines, comments and invalid patterns.
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// A pattern with a slash is relative to the directory of the ignore
+	// file, otherwise it matches a name at any level below it.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := "^"
+	if base != "" {
+		expr += regexp.QuoteMeta(base) + "/"
+	}
+	if !anchored {
+		expr += "(?:.*/)?"
+	}
+	expr += globToRegexp(line) + "$"
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	rule.regexp = re
+	return rule, true
+}
+
+// globToRegexp converts a ".gitignore" glob into a regular expression.
+func globToRegexp(glob string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			buf.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			buf.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.Index(glob[i+1:], "]")
+			if end < 0 {
+				buf.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.Replace(class, "\\", "\\\\", -1) + "]")
+			i += end + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
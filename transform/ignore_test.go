@@ -0,0 +1,171 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	cases := []struct {
+		pattern, base, path string
+		isDir, expected     bool
+	}{
+		{"*.class", "", "Main.class", false, true},
+		{"*.class", "", "src/main/Main.class", false, true},
+		{"*.class", "src", "src/main/Main.class", false, true},
+		{"*.class", "src", "other/Main.class", false, false},
+		{"target/", "", "module/target", true, true},
+		{"target/", "", "module/target", false, false},
+		{"/target", "", "target", true, true},
+		{"/target", "", "module/target", true, false},
+		{"doc/*.html", "", "doc/index.html", false, true},
+		{"doc/*.html", "", "doc/api/index.html", false, false},
+		{"**/generated", "", "a/b/generated", true, true},
+		{"doc/**/*.png", "", "doc/img/logo/seed.png", false, true},
+		{"doc/**/*.png", "", "doc/seed.png", false, true},
+		{"logs/**", "", "logs/2015/app.log", false, true},
+		{"file[0-9].txt", "", "file1.txt", false, true},
+		{"file[!0-9].txt", "", "file1.txt", false, false},
+		{"file?.txt", "", "fileA.txt", false, true},
+		{"\\#notes", "", "#notes", false, true},
+	}
+	for _, c := range cases {
+		rule, ok := parseIgnoreRule(c.pattern, c.base)
+		if !ok {
+			t.Errorf("The pattern %s should be valid", c.pattern)
+			continue
+		}
+		matched := rule.regexp.MatchString(c.path) && (c.isDir || !rule.dirOnly)
+		if matched != c.expected {
+			t.Errorf("The pattern %s in %q should match %s: %v but found %v", c.pattern, c.base, c.path, c.expected, matched)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := parseIgnoreRule(line, ""); ok {
+			t.Errorf("The line %q should not be a rule", line)
+		}
+	}
+}
+
+var ignoredTree = map[string]string{
+	".git/HEAD":               "",
+	".git/info/exclude":       "*.swp\n",
+	".gitignore":              "target/\n*.log\n!keep.log\n",
+	"module/.gitignore":       "*.tmp\n",
+	"module/.seedignore":      "generated/\n",
+	"module/pom.xml":          "",
+	"module/app.tmp":          "",
+	"module/app.log":          "",
+	"module/keep.log":         "",
+	"module/Main.java.swp":    "",
+	"module/target/App.jar":   "",
+	"module/generated/A.java": "",
+	"other/app.tmp":           "",
+}
+
+func walkedFiles(t *testing.T, dir string, root string, gitIgnore bool) []string {
+	files, errs := Walk(root, "", "", Options{GitIgnore: gitIgnore})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	var res []string
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, filepath.ToSlash(rel))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestWalkDirWithIgnoreFiles(t *testing.T) {
+	dir := writeTdfs(t, ignoredTree)
+	defer os.RemoveAll(dir)
+
+	files := walkedFiles(t, dir, dir, true)
+	expected := ".gitignore,module/.gitignore,module/.seedignore,module/keep.log,module/pom.xml,other/app.tmp"
+	if strings.Join(files, ",") != expected {
+		t.Errorf("The ignored files should be skipped, expected %s but found %v", expected, files)
+	}
+
+	// The ignore files of the parent directories are used
+	files = walkedFiles(t, dir, filepath.Join(dir, "module"), true)
+	expected = "module/.gitignore,module/.seedignore,module/keep.log,module/pom.xml"
+	if strings.Join(files, ",") != expected {
+		t.Errorf("The ignored files should be skipped, expected %s but found %v", expected, files)
+	}
+}
+
+func TestUnignored(t *testing.T) {
+	dir := writeTdfs(t, ignoredTree)
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for _, name := range []string{"module/pom.xml", "module/app.tmp", "module/keep.log", "module/target/App.jar", "module/generated/A.java", "other/app.tmp"} {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	res, err := Unignored(filepath.Join(dir, "module"), files[:5], Options{GitIgnore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0] != files[0] || res[1] != files[2] {
+		t.Errorf("The ignored files should be skipped like in a walk but found %v", res)
+	}
+	if res, _ = Unignored(dir, files, Options{}); len(res) != 5 {
+		t.Errorf("Only the .seedignore files should be used but found %v", res)
+	}
+}
+
+func TestWalkWorkTree(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{
+		"main/.git/HEAD":                     "",
+		"main/.git/info/exclude":             "*.swp\n",
+		"main/.git/worktrees/wt/commondir":   "../..\n",
+		"main/.git/modules/lib/info/exclude": "*.bak\n",
+		"wt/App.java":                        "",
+		"wt/App.java.swp":                    "",
+		"main/lib/.git":                      "gitdir: ../.git/modules/lib\n",
+		"main/lib/Lib.java":                  "",
+		"main/lib/Lib.java.bak":              "",
+	})
+	defer os.RemoveAll(dir)
+	gitFile := "gitdir: " + filepath.Join(dir, "main", ".git", "worktrees", "wt") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "wt", ".git"), []byte(gitFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The exclude file of a linked work tree is in the main repository
+	if files := walkedFiles(t, dir, filepath.Join(dir, "wt"), true); strings.Join(files, ",") != "wt/App.java" {
+		t.Errorf("The excluded files of the work tree should be skipped but found %v", files)
+	}
+	if files := walkedFiles(t, dir, filepath.Join(dir, "main", "lib"), true); strings.Join(files, ",") != "main/lib/Lib.java" {
+		t.Errorf("The excluded files of the submodule should be skipped but found %v", files)
+	}
+}
+
+func TestWalkDirWithoutGitIgnore(t *testing.T) {
+	dir := writeTdfs(t, ignoredTree)
+	defer os.RemoveAll(dir)
+
+	files := walkedFiles(t, dir, filepath.Join(dir, "module"), false)
+	expected := "module/.gitignore,module/.seedignore,module/Main.java.swp,module/app.log,module/app.tmp," +
+		"module/keep.log,module/pom.xml,module/target/App.jar"
+	if strings.Join(files, ",") != expected {
+		t.Errorf("Only the .seedignore files should be used, expected %s but found %v", expected, files)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Load reads and parses the transformation file at the given path or URL,
+// then merges the files it includes. The transformations of the included files
+// come first, in the order of the include list, followed by the transformations
+// of the including file. Each transformation records the file it comes from.
+func Load(path string) (T, error) {
+	return loadWithIncludes(path, nil)
+}
+
+func loadWithIncludes(path string, stack []string) (T, error) {
+	location := tdfLocation(path)
+	for _, previous := range stack {
+		if previous == location {
+			return T{}, fmt.Errorf("include cycle detected: %s -> %s", strings.Join(stack, " -> "), location)
+		}
+	}
+	stack = append(stack, location)
+
+	format, err := Format(path)
+	if err != nil {
+		return T{}, fmt.Errorf("unsupported format for %s", path)
+	}
+
+	var dat []byte
+	if IsURL(path) {
+		dat, err = fetchURL(path)
+	} else {
+		dat, err = readFile(path)
+	}
+	if err != nil {
+		return T{}, err
+	}
+	t, err := Parse(dat, format)
+	if err != nil {
+		return T{}, fmt.Errorf("%s: %s", path, err)
+	}
+
+	res := T{contents: map[string][]byte{path: dat}}
+	var excludes []string
+	var includedVars []map[string]string
+	for _, include := range t.Include {
+		included, err := loadWithIncludes(resolveInclude(location, include), stack)
+		if err != nil {
+			return T{}, err
+		}
+		if included.Exclude != "" {
+			excludes = append(excludes, included.Exclude)
+		}
+		includedVars = append(includedVars, included.Vars)
+		for source, content := range included.contents {
+			res.contents[source] = content
+		}
+		res.Transformations = append(res.Transformations, included.Transformations...)
+		res.Post = append(res.Post, included.Post...)
+	}
+
+	if t.Exclude != "" {
+		excludes = append(excludes, t.Exclude)
+	}
+	res.Exclude = strings.Join(excludes, "|")
+	res.Vars = MergeVars(append(includedVars, t.Vars)...)
+
+	for _, transf := range t.Transformations {
+		transf.Source = path
+		res.Transformations = append(res.Transformations, transf)
+	}
+	for _, post := range t.Post {
+		post.Source = path
+		res.Post = append(res.Post, post)
+	}
+	return res, nil
+}
+
+// resolveInclude returns the location of an included file. Relative paths
+// are resolved against the location of the including file.
+func resolveInclude(parent, include string) string {
+	if IsURL(include) {
+		return include
+	}
+	if IsURL(parent) {
+		base, err := url.Parse(parent)
+		if err != nil {
+			return include
+		}
+		ref, err := url.Parse(filepath.ToSlash(include))
+		if err != nil {
+			return include
+		}
+		return base.ResolveReference(ref).String()
+	}
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(parent), include)
+}
+
+// tdfLocation returns a canonical location for the transformation file
+// in order to detect include cycles.
+func tdfLocation(path string) string {
+	if IsURL(path) {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return absPath
+}
+
+// IsURL returns true if the transformation file is fetched with HTTP.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTdfs(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "seed-include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadTdfWithIncludes(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{
+		"main.yml": `include:
+ - "parts/maven.yml"
+ - "parts/java.toml"
+exclude: "target"
+vars:
+  version: "16.4"
+transformations:
+ -
+  filter: "*.properties"
+post:
+ -
+  command: ["mvn", "-q", "formatter:format"]
+`,
+		"parts/maven.yml": `exclude: ".git"
+vars:
+  version: "15.4"
+  group: "org.seedstack"
+transformations:
+ -
+  filter: "pom.xml"
+`,
+		"parts/java.toml": `include = [ "../common.yml" ]
+
+[[transformations]]
+  filter = "*.java"
+`,
+		"common.yml": `transformations:
+ -
+  filter: "*.txt"
+post:
+ -
+  filter: "*.txt"
+  command: ["dos2unix"]
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	mainPath := filepath.Join(dir, "main.yml")
+	tr, err := Load(mainPath)
+	if err != nil {
+		t.Fatalf("No error was expected but found %v", err)
+	}
+
+	var filters []string
+	for _, transf := range tr.Transformations {
+		filters = append(filters, transf.Filter)
+	}
+	if strings.Join(filters, ",") != "pom.xml,*.txt,*.java,*.properties" {
+		t.Errorf("The included transformations should come first but found %v", filters)
+	}
+	if len(tr.Post) != 2 || tr.Post[0].Command[0] != "dos2unix" || tr.Post[1].Source != mainPath {
+		t.Errorf("The included post commands should come first but found %v", tr.Post)
+	}
+	if tr.Exclude != ".git|target" {
+		t.Errorf("The exclusions should be merged but found %s", tr.Exclude)
+	}
+	if tr.Vars["version"] != "16.4" || tr.Vars["group"] != "org.seedstack" {
+		t.Errorf("The variables of the including file should win but found %v", tr.Vars)
+	}
+	if tr.Transformations[1].Source != filepath.Join(dir, "common.yml") {
+		t.Errorf("The source of the transformation should be common.yml but found %s", tr.Transformations[1].Source)
+	}
+	if tr.Transformations[3].Source != mainPath {
+		t.Errorf("The source of the transformation should be main.yml but found %s", tr.Transformations[3].Source)
+	}
+}
+
+func TestLoadTdfWithIncludeCycle(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{
+		"a.yml": `include: [ "b.yml" ]`,
+		"b.yml": `include: [ "./a.yml" ]`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := Load(filepath.Join(dir, "a.yml"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle detected") {
+		t.Errorf("An include cycle error was expected but found %v", err)
+	}
+}
+
+func TestResolveInclude(t *testing.T) {
+	cases := []struct{ parent, include, expected string }{
+		{"https://example.com/tdf/main.yml", "maven.yml", "https://example.com/tdf/maven.yml"},
+		{"https://example.com/tdf/main.yml", "../common.yml", "https://example.com/common.yml"},
+		{"https://example.com/tdf/main.yml", "http://other.com/java.yml", "http://other.com/java.yml"},
+		{filepath.FromSlash("tdf/main.yml"), "maven.yml", filepath.FromSlash("tdf/maven.yml")},
+		{filepath.FromSlash("tdf/main.yml"), "https://example.com/java.yml", "https://example.com/java.yml"},
+	}
+	for _, c := range cases {
+		if res := resolveInclude(c.parent, c.include); res != c.expected {
+			t.Errorf("resolveInclude(%s, %s): %s was expected but found %s", c.parent, c.include, c.expected, res)
+		}
+	}
+}
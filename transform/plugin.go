@@ -0,0 +1,89 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PluginRequest is written as JSON on the standard input of a plugin.
+type PluginRequest struct {
+	// File is the path of the transformed file
+	File string `json:"file"`
+	// Content is the content of the file, transformed by the previous procedures
+	Content string `json:"content"`
+	// Params are the parameters of the procedure after the command
+	Params []string `json:"params"`
+}
+
+// PluginResponse is read as JSON from the standard output of a plugin.
+type PluginResponse struct {
+	// Content is the new content of the file, the file is unchanged if
+	// it is missing
+	Content *string `json:"content,omitempty"`
+	// Error fails the transformation of the file
+	Error string `json:"error,omitempty"`
+}
+
+func init() {
+	RegisterProcedure(ProcedureDef{
+		Name: "Plugin",
+		Description: "Runs an external executable to transform the file. The executable receives a JSON " +
+			"object with the \"file\" path, its \"content\" and the \"params\" on its standard input, and " +
+			"writes a JSON object with the new \"content\", or an \"error\", on its standard output. " +
+			"It is stopped after the timeout set with -timeout. Like the other procedures, at most -j " +
+			"plugins run concurrently.",
+		Params: []Param{
+			{Name: "command", Description: "the path of the executable, or its name in the PATH"},
+			{Name: "params", Description: "the parameters passed to the executable", Variadic: true},
+		},
+		Examples: []string{"proc:\n  - name: Plugin\n    params: [\"./plugins/migrate-annotations\", \"javax\", \"jakarta\"]"},
+		Validate: func(params []string) error {
+			return lookCommand(params[0])
+		},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			return runPlugin(params[0], PluginRequest{File: env.File, Content: string(data), Params: params[1:]}, env)
+		},
+	})
+}
+
+// runPlugin sends the request to the plugin and returns the new content
+// of the file. The messages written by the plugin on its standard error
+// are returned with its failure or written to env.Out.
+func runPlugin(command string, req PluginRequest, env Env) ([]byte, error) {
+	if req.Params == nil {
+		req.Params = []string{}
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := execute([]string{command}, input, nil, env.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if env.Out != nil && len(stderr) > 0 {
+		env.Out.Write(stderr)
+	}
+
+	var resp PluginResponse
+	if err = json.Unmarshal(stdout, &resp); err != nil {
+		return nil, fmt.Errorf("%s returned an invalid response: %s", command, err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Content == nil {
+		return []byte(req.Content), nil
+	}
+	return []byte(*resp.Content), nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as a plugin, or as a command, when
+// SEED_TEST_PLUGIN is set.
+func TestMain(m *testing.M) {
+	mode := os.Getenv("SEED_TEST_PLUGIN")
+	if mode == "" {
+		os.Exit(m.Run())
+	}
+	if strings.HasPrefix(mode, "command-") {
+		runTestCommand(mode)
+		os.Exit(0)
+	}
+
+	var req PluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var resp PluginResponse
+	switch mode {
+	case "upper":
+		content := strings.ToUpper(req.Content) + strings.Join(req.Params, " ")
+		resp.Content = &content
+		fmt.Fprintf(os.Stderr, "transformed %s\n", req.File)
+	case "error":
+		resp.Error = "unsupported annotation"
+	case "exit":
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(2)
+	case "sleep":
+		time.Sleep(10 * time.Second)
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+func applyPlugin(t *testing.T, mode string, timeout time.Duration) (string, error) {
+	os.Setenv("SEED_TEST_PLUGIN", mode)
+	defer os.Unsetenv("SEED_TEST_PLUGIN")
+
+	p := []Procedure{Procedure{Name: "Plugin", Params: []string{os.Args[0], "a", "b"}}}
+	tr := T{Transformations: []Transformation{Transformation{Filter: "*.java", Proc: p}}}
+	res, err := Apply("App.java", []byte("foo "), tr, Options{Timeout: timeout})
+	return string(res), err
+}
+
+func TestPlugin(t *testing.T) {
+	if res, err := applyPlugin(t, "upper", time.Minute); res != "FOO a b" || err != nil {
+		t.Errorf("The plugin should transform the content but found %q, %v", res, err)
+	}
+	if res, err := applyPlugin(t, "unchanged", time.Minute); res != "foo " || err != nil {
+		t.Errorf("The content should be unchanged without content in the response but found %q, %v", res, err)
+	}
+
+	// Only the sleeping plugin has a short timeout, starting the test binary
+	// can be slow, for instance with the race detector
+	for _, c := range []struct {
+		mode     string
+		timeout  time.Duration
+		expected string
+	}{
+		{"error", time.Minute, "App.java: transformation 1: Plugin: unsupported annotation"},
+		{"exit", time.Minute, "failed: exit status 2: boom"},
+		{"sleep", 500 * time.Millisecond, "timed out after 500ms"},
+	} {
+		if _, err := applyPlugin(t, c.mode, c.timeout); err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("The %s plugin should fail with %q but found %v", c.mode, c.expected, err)
+		}
+	}
+}
+
+func TestValidatePlugin(t *testing.T) {
+	def, _ := LookupProcedure("Plugin")
+	if err := def.checkParams([]string{os.Args[0], "a"}); err != nil {
+		t.Errorf("The test binary should be a valid plugin but found %v", err)
+	}
+	if err := def.checkParams([]string{"./missing-plugin"}); err == nil {
+		t.Error("A missing plugin should be reported")
+	}
+	if err := def.checkParams(nil); err == nil {
+		t.Error("The command of the plugin should be required")
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Param describes a parameter of a procedure.
+type Param struct {
+	Name        string
+	Description string
+	// Variadic marks the last parameter of a procedure accepting any number
+	// of values for it
+	Variadic bool
+}
+
+// ProcedureDef declares a procedure which can be used in the "proc" section
+// of a transformation.
+type ProcedureDef struct {
+	Name        string
+	Description string
+	Params      []Param
+	// Examples are snippets of transformation files using the procedure
+	Examples []string
+	// Validate checks the parameters beyond their number, it is optional
+	Validate func(params []string) error
+	// Apply transforms the content of a file
+	Apply func(data []byte, params []string, env Env) ([]byte, error)
+}
+
+// Env is the environment of a procedure or a precondition applied on a file.
+type Env struct {
+	// File is the path of the transformed file
+	File string
+	// Out receives the very verbose messages of the procedure
+	Out io.Writer
+	// Timeout limits the duration of the commands and scripts run by the
+	// procedure, 0 for no limit
+	Timeout time.Duration
+
+	replaced func(n int)
+}
+
+// Replaced records that the procedure made n replacements, for the
+// statistics of the run. The procedures which don't call it count one
+// replacement when they change the content.
+func (e Env) Replaced(n int) {
+	if e.replaced != nil {
+		e.replaced(n)
+	}
+}
+
+// PreconditionDef declares a precondition which can be used in the "pre"
+// section of a transformation.
+type PreconditionDef struct {
+	Name        string
+	Description string
+	// Arg describes the argument written after the name of the precondition,
+	// as in "Name(argument)", or is nil if it takes no argument
+	Arg      *Param
+	Examples []string
+	// Validate checks the argument, it is optional
+	Validate func(arg string) error
+	// Check returns true if the transformation can be applied on the file
+	Check func(data []byte, arg string, env Env) (bool, error)
+}
+
+var (
+	registryMu    sync.RWMutex
+	procedures    = make(map[string]ProcedureDef)
+	preconditions = make(map[string]PreconditionDef)
+)
+
+// RegisterProcedure makes a procedure available to the transformation
+// files. It panics if the definition is incomplete or if a procedure with
+// the same name is already registered.
+func RegisterProcedure(def ProcedureDef) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if def.Name == "" || def.Apply == nil {
+		panic("seed: RegisterProcedure requires a name and an Apply function")
+	}
+	if _, dup := procedures[def.Name]; dup {
+		panic("seed: RegisterProcedure called twice for " + def.Name)
+	}
+	for i, param := range def.Params {
+		if param.Variadic && i != len(def.Params)-1 {
+			panic("seed: only the last parameter of " + def.Name + " can be variadic")
+		}
+	}
+	procedures[def.Name] = def
+}
+
+// RegisterPrecondition makes a precondition available to the transformation
+// files. It panics if the definition is incomplete or if a precondition with
+// the same name is already registered.
+func RegisterPrecondition(def PreconditionDef) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if def.Name == "" || def.Check == nil {
+		panic("seed: RegisterPrecondition requires a name and a Check function")
+	}
+	if _, dup := preconditions[def.Name]; dup {
+		panic("seed: RegisterPrecondition called twice for " + def.Name)
+	}
+	preconditions[def.Name] = def
+}
+
+// LookupProcedure returns the registered procedure with the given name.
+func LookupProcedure(name string) (ProcedureDef, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := procedures[name]
+	return def, ok
+}
+
+// LookupPrecondition returns the registered precondition with the given name.
+func LookupPrecondition(name string) (PreconditionDef, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := preconditions[name]
+	return def, ok
+}
+
+// RegisteredProcedures returns the registered procedures sorted by name.
+func RegisteredProcedures() []ProcedureDef {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var defs []ProcedureDef
+	for _, def := range procedures {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// RegisteredPreconditions returns the registered preconditions sorted by name.
+func RegisteredPreconditions() []PreconditionDef {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var defs []PreconditionDef
+	for _, def := range preconditions {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// checkParams checks the parameters passed to the procedure against its
+// parameter schema and its validation function.
+func (def ProcedureDef) checkParams(params []string) error {
+	required := len(def.Params)
+	variadic := required > 0 && def.Params[required-1].Variadic
+	if variadic {
+		required--
+		if len(params) < required {
+			return fmt.Errorf("expects at least %v parameters but found %v", required, len(params))
+		}
+	} else if len(params) != required {
+		return fmt.Errorf("expects %v parameters but found %v", required, len(params))
+	}
+	if def.Validate != nil {
+		return def.Validate(params)
+	}
+	return nil
+}
+
+// apply checks the parameters and applies the procedure. A panic of the
+// procedure is returned as an error.
+func (def ProcedureDef) apply(data []byte, params []string, env Env) (res []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if err = def.checkParams(params); err != nil {
+		return nil, err
+	}
+	return def.Apply(data, params, env)
+}
+
+// splitPrecondition splits a precondition of the "pre" section in its name
+// and its argument, if it is written as "Name(argument)".
+func splitPrecondition(pre string) (name, arg string, hasArg bool) {
+	pre = strings.TrimSpace(pre)
+	open := strings.Index(pre, "(")
+	if open <= 0 || !strings.HasSuffix(pre, ")") {
+		return pre, "", false
+	}
+	return strings.TrimSpace(pre[:open]), pre[open+1 : len(pre)-1], true
+}
+
+// checkArg checks the argument passed to the precondition against its
+// declaration and its validation function.
+func (def PreconditionDef) checkArg(arg string, hasArg bool) error {
+	if def.Arg == nil && hasArg {
+		return errors.New("takes no argument")
+	}
+	if def.Arg != nil && !hasArg {
+		return fmt.Errorf("expects an argument, written %s(%s)", def.Name, def.Arg.Name)
+	}
+	if def.Validate != nil {
+		return def.Validate(arg)
+	}
+	return nil
+}
+
+// check checks the argument and applies the precondition. A panic of the
+// precondition is returned as an error.
+func (def PreconditionDef) check(data []byte, arg string, hasArg bool, env Env) (ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if err = def.checkArg(arg, hasArg); err != nil {
+		return false, err
+	}
+	return def.Check(data, arg, env)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRegisterProcedure(t *testing.T) {
+	RegisterProcedure(ProcedureDef{
+		Name:   "TestUpper",
+		Params: []Param{{Name: "prefix"}, {Name: "words", Variadic: true}},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			res := params[0] + string(bytes.ToUpper(data))
+			for _, word := range params[1:] {
+				res += " " + word
+			}
+			return []byte(res), nil
+		},
+	})
+
+	def, ok := LookupProcedure("TestUpper")
+	if !ok {
+		t.Fatal("TestUpper should be registered")
+	}
+	if err := def.checkParams(nil); err == nil || err.Error() != "expects at least 1 parameters but found 0" {
+		t.Errorf("The missing parameters should be reported but found %v", err)
+	}
+
+	tr := Transformation{Proc: []Procedure{Procedure{Name: "TestUpper", Params: []string{"> ", "a", "b"}}}}
+	res, err := applyProcs([]byte("foo"), tr, Env{Out: ioutil.Discard}, nil)
+	if string(res) != "> FOO a b" || err != nil {
+		t.Errorf("The registered procedure should be applied but found %s, %v", res, err)
+	}
+
+	found := false
+	for _, def := range RegisteredProcedures() {
+		found = found || def.Name == "TestUpper"
+	}
+	if !found {
+		t.Error("The registered procedure should be listed")
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "called twice for Insert") {
+			t.Errorf("Registering a procedure twice should panic but found %v", r)
+		}
+	}()
+	RegisterProcedure(ProcedureDef{Name: "Insert", Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+		return data, nil
+	}})
+}
+
+func TestBuiltinsHaveDocumentation(t *testing.T) {
+	for _, def := range RegisteredProcedures() {
+		if strings.HasPrefix(def.Name, "Test") || def.Name == "DoNothing" {
+			continue
+		}
+		if def.Description == "" || len(def.Examples) == 0 {
+			t.Errorf("The procedure %s should have a description and examples", def.Name)
+		}
+	}
+	for _, def := range RegisteredPreconditions() {
+		if def.Name != "AlwaysFalse" && def.Description == "" {
+			t.Errorf("The precondition %s should have a description", def.Name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report collects what the transformations did on each file of a run when
+// it is set in the options. It can be shared by concurrent runs.
+type Report struct {
+	mu    sync.Mutex
+	files []FileReport
+}
+
+// FileReport describes the transformations of a file whose name matches
+// the filter of at least one transformation.
+type FileReport struct {
+	File            string                  `json:"file"`
+	Transformations []*TransformationReport `json:"transformations"`
+	// Changed is true if the content of the file was modified
+	Changed      bool   `json:"changed"`
+	BytesBefore  int    `json:"bytesBefore"`
+	BytesAfter   int    `json:"bytesAfter"`
+	LinesAdded   int    `json:"linesAdded"`
+	LinesRemoved int    `json:"linesRemoved"`
+	Error        string `json:"error,omitempty"`
+	// DurationMs is the time spent transforming the file in milliseconds
+	DurationMs float64 `json:"durationMs"`
+
+	orig  []byte
+	start time.Time
+	// lines are the original lines of the current content, see origLine
+	lines []int
+}
+
+// TransformationReport describes a transformation whose filter matches
+// the file.
+type TransformationReport struct {
+	// Index is the position of the transformation, includes first,
+	// starting at 1
+	Index  int    `json:"index"`
+	Source string `json:"source,omitempty"`
+	Filter string `json:"filter"`
+	// AlreadyApplied is true if the transformation is marked as "once"
+	// and was applied to the file by a previous run
+	AlreadyApplied bool                 `json:"alreadyApplied,omitempty"`
+	Preconditions  []PreconditionReport `json:"preconditions,omitempty"`
+	// Applied is true if the preconditions passed and the procedures ran
+	Applied    bool              `json:"applied"`
+	Procedures []ProcedureReport `json:"procedures,omitempty"`
+	// Changes are the lines of the original file modified by the
+	// transformation
+	Changes []LineRange `json:"changes,omitempty"`
+	// Error is the failure of the transformation on the file
+	Error string `json:"error,omitempty"`
+	// Replacements is the number of replacements made by the procedures
+	Replacements int `json:"replacements"`
+	// DurationMs is the time spent checking the preconditions and applying
+	// the procedures in milliseconds
+	DurationMs float64 `json:"durationMs"`
+}
+
+// lineEdit is a change of the content of a file, where the lines from
+// start to oldEnd, excluded, are replaced by the lines from start to
+// newEnd. The lines start at 0.
+type lineEdit struct {
+	start, oldEnd, newEnd int
+}
+
+// LineRange is a range of lines, starting at 1, including its end. The
+// lines inserted by a transformation are located at the line before
+// which they are inserted, or at the last line at the end of the file.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// PreconditionReport is the result of a precondition. The preconditions
+// following a failed one are not checked.
+type PreconditionReport struct {
+	Precondition string `json:"precondition"`
+	Passed       bool   `json:"passed"`
+}
+
+// ProcedureReport tells if a procedure modified the content of the file.
+type ProcedureReport struct {
+	Name    string `json:"name"`
+	Changed bool   `json:"changed"`
+	// Replacements is the number of replacements made by the procedure,
+	// or 1 if it changed the content without counting them
+	Replacements int `json:"replacements"`
+}
+
+// Files returns the reports of the files sorted by path.
+func (r *Report) Files() []FileReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := append([]FileReport{}, r.files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].File < files[j].File })
+	return files
+}
+
+// start returns the report of a file about to be transformed, or nil if
+// the run is not reported.
+func (r *Report) start(filePath string, data []byte) *FileReport {
+	if r == nil {
+		return nil
+	}
+	return &FileReport{File: filePath, BytesBefore: len(data), orig: data, start: time.Now()}
+}
+
+// finish completes the report of the file with the transformed content,
+// or the error, and adds it to the run if a transformation matched.
+func (r *Report) finish(rep *FileReport, data []byte, err error) {
+	if rep == nil || len(rep.Transformations) == 0 {
+		return
+	}
+	rep.DurationMs = float64(time.Since(rep.start)) / float64(time.Millisecond)
+	rep.BytesAfter = rep.BytesBefore
+	if err != nil {
+		rep.Error = err.Error()
+		var transfErr *TransformError
+		if errors.As(err, &transfErr) {
+			for _, tr := range rep.Transformations {
+				if tr.Index == transfErr.index {
+					tr.Error = transfErr.Err.Error()
+				}
+			}
+		}
+	} else if !bytes.Equal(rep.orig, data) {
+		rep.Changed = true
+		rep.BytesAfter = len(data)
+		ops := diffLines(splitLines(rep.orig), splitLines(data))
+		for _, op := range ops {
+			switch op.Kind {
+			case '+':
+				rep.LinesAdded++
+			case '-':
+				rep.LinesRemoved++
+			}
+		}
+	}
+	rep.orig, rep.lines = nil, nil
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = append(r.files, *rep)
+}
+
+// transformation adds a transformation matching the file to its report.
+func (rep *FileReport) transformation(index int, t Transformation) *TransformationReport {
+	if rep == nil {
+		return nil
+	}
+	tr := &TransformationReport{Index: index, Source: t.Source, Filter: t.Filter}
+	rep.Transformations = append(rep.Transformations, tr)
+	return tr
+}
+
+func (tr *TransformationReport) precondition(pre string, passed bool) {
+	if tr != nil {
+		tr.Preconditions = append(tr.Preconditions, PreconditionReport{Precondition: pre, Passed: passed})
+	}
+}
+
+// timed records the time spent on the transformation since started.
+func (tr *TransformationReport) timed(started time.Time) {
+	if tr != nil {
+		tr.DurationMs += float64(time.Since(started)) / float64(time.Millisecond)
+	}
+}
+
+// edited records that the transformation changed before into after. The
+// lines between the common start and end of the contents are diffed, and
+// each group of changes is located in the original content through the
+// original lines of the current ones.
+func (rep *FileReport) edited(tr *TransformationReport, before, after []byte) {
+	if tr == nil || bytes.Equal(before, after) {
+		return
+	}
+	origLines := countLines(rep.orig)
+	if rep.lines == nil {
+		rep.lines = make([]int, origLines)
+		for i := range rep.lines {
+			rep.lines[i] = i
+		}
+	}
+	e := changedLines(before, after)
+	ops := diffLines(splitLines(before[lineOffset(before, e.start):lineOffset(before, e.oldEnd)]),
+		splitLines(after[lineOffset(after, e.start):lineOffset(after, e.newEnd)]))
+
+	lines := append(make([]int, 0, len(rep.lines)+e.newEnd-e.oldEnd), rep.lines[:e.start]...)
+	old := e.start
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			lines = append(lines, rep.lines[old])
+			old, i = old+1, i+1
+			continue
+		}
+		start := old
+		var added int
+		for ; i < len(ops) && ops[i].Kind != ' '; i++ {
+			if ops[i].Kind == '-' {
+				old++
+			} else {
+				added++
+			}
+		}
+		// The added lines replace the removed ones, the others are
+		// inserted before the next line
+		next := -1 - origLines
+		if old < len(rep.lines) {
+			next = -1 - rep.origLine(old)
+		}
+		for k := 0; k < added; k++ {
+			if start+k < old {
+				lines = append(lines, rep.lines[start+k])
+			} else {
+				lines = append(lines, next)
+			}
+		}
+		if start < old {
+			tr.change(rep.origLine(start), rep.origLine(old-1), origLines)
+		} else {
+			tr.change(-1-next, -1-next, origLines)
+		}
+	}
+	rep.lines = append(lines, rep.lines[e.oldEnd:]...)
+}
+
+// origLine returns the original line of a line of the current content.
+// The lines are the original lines, starting at 0, or -1 minus the line
+// before which they were inserted.
+func (rep *FileReport) origLine(line int) int {
+	if orig := rep.lines[line]; orig >= 0 {
+		return orig
+	}
+	return -1 - rep.lines[line]
+}
+
+// changedLines returns the lines of before replaced by after, between
+// their common lines at the start and at the end.
+func changedLines(before, after []byte) lineEdit {
+	n := len(before)
+	if len(after) < n {
+		n = len(after)
+	}
+	k := 0
+	for k < n && before[k] == after[k] {
+		k++
+	}
+	// Only the complete lines are common
+	k = bytes.LastIndexByte(before[:k], '\n') + 1
+
+	j := 0
+	for j < n-k && before[len(before)-1-j] == after[len(after)-1-j] {
+		j++
+	}
+	suffix := before[len(before)-j:]
+	if !lineStart(before, len(before)-j) || !lineStart(after, len(after)-j) {
+		// The first common line is not complete
+		if i := bytes.IndexByte(suffix, '\n'); i >= 0 {
+			suffix = suffix[i+1:]
+		} else {
+			suffix = nil
+		}
+	}
+	common := countLines(suffix)
+	return lineEdit{start: bytes.Count(before[:k], []byte{'\n'}),
+		oldEnd: countLines(before) - common, newEnd: countLines(after) - common}
+}
+
+// lineOffset returns the offset of the line of the data, starting at 0,
+// or the length of the data after its last line.
+func lineOffset(data []byte, line int) int {
+	offset := 0
+	for ; line > 0; line-- {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return len(data)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+func lineStart(data []byte, i int) bool {
+	return i == 0 || data[i-1] == '\n'
+}
+
+// countLines returns the number of lines of the data, the last one may
+// not end with an end of line.
+func countLines(data []byte) int {
+	n := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// change records the original lines from start to end, included, changed
+// by the transformation. The lines start at 0 and the changes at the end
+// of a file of the given lines are located at its last line.
+func (tr *TransformationReport) change(start, end, lines int) {
+	clamp := func(line int) int {
+		if line > lines {
+			line = lines
+		}
+		if line < 1 {
+			line = 1
+		}
+		return line
+	}
+	r := LineRange{Start: clamp(start + 1), End: clamp(end + 1)}
+	if n := len(tr.Changes); n > 0 && tr.Changes[n-1].End >= r.Start-1 {
+		if r.End > tr.Changes[n-1].End {
+			tr.Changes[n-1].End = r.End
+		}
+		return
+	}
+	tr.Changes = append(tr.Changes, r)
+}
+
+// procedure records a procedure which transformed before into after, and
+// made the given number of replacements, or -1 if it didn't count them.
+func (tr *TransformationReport) procedure(name string, before, after []byte, replaced int) {
+	if tr == nil {
+		return
+	}
+	changed := !bytes.Equal(before, after)
+	if replaced < 0 {
+		replaced = 0
+		if changed {
+			replaced = 1
+		}
+	}
+	tr.Procedures = append(tr.Procedures, ProcedureReport{Name: name, Changed: changed, Replacements: replaced})
+	tr.Replacements += replaced
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "*.java", Pre: []string{"AlwaysTrue", `Script("javax" in content)`},
+			Proc: []Procedure{
+				Procedure{Name: "Replace", Params: []string{"javax", "jakarta"}},
+				Procedure{Name: "EnsureInsert", Params: []string{"\n"}},
+			}},
+		Transformation{Filter: "*.xml", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"<x/>\n"}}}},
+		Transformation{Filter: "Bad.java", Proc: []Procedure{Procedure{Name: "Unknown"}}},
+	}}
+	report := &Report{}
+	opts := Options{Report: report}
+	Apply("pom.xml", []byte("<project/>\n"), tr, opts)
+	Apply("App.java", []byte("import javax.inject;\n"), tr, opts)
+	Apply("Old.java", []byte("import java.util;\n"), tr, opts)
+	Apply("Bad.java", []byte("import javax.inject;\n"), tr, opts)
+	Apply("README.md", []byte("# App\n"), tr, opts)
+
+	files := report.Files()
+	var names []string
+	for _, f := range files {
+		names = append(names, f.File)
+	}
+	if !reflect.DeepEqual(names, []string{"App.java", "Bad.java", "Old.java", "pom.xml"}) {
+		t.Fatalf("The files matching a filter should be reported in order but found %v", names)
+	}
+
+	app := files[0]
+	if !app.Changed || app.BytesBefore != 21 || app.BytesAfter != 23 || app.LinesAdded != 1 || app.LinesRemoved != 1 {
+		t.Errorf("The changes of App.java should be measured but found %+v", app)
+	}
+	applied := app.Transformations[0]
+	expectedPre := []PreconditionReport{{"AlwaysTrue", true}, {`Script("javax" in content)`, true}}
+	expectedProcs := []ProcedureReport{{"Replace", true, 1}, {"EnsureInsert", false, 0}}
+	if len(app.Transformations) != 1 || !applied.Applied || applied.Index != 1 ||
+		!reflect.DeepEqual(applied.Preconditions, expectedPre) || !reflect.DeepEqual(applied.Procedures, expectedProcs) {
+		t.Errorf("The transformation of App.java should be detailed but found %+v", applied)
+	}
+
+	if bad := files[1]; bad.Changed || bad.Error != "Bad.java: transformation 3: Unknown: unknown procedure" {
+		t.Errorf("The error of Bad.java should be reported but found %+v", bad)
+	}
+
+	old := files[2].Transformations[0]
+	if files[2].Changed || old.Applied || len(old.Preconditions) != 2 || old.Preconditions[1].Passed || old.Procedures != nil {
+		t.Errorf("The failed precondition of Old.java should be reported but found %+v", old)
+	}
+
+	if pom := files[3]; !pom.Changed || pom.LinesAdded != 1 || pom.LinesRemoved != 0 || pom.Transformations[0].Index != 2 {
+		t.Errorf("The insertion in pom.xml should be reported but found %+v", pom)
+	}
+}
+
+func TestReportChanges(t *testing.T) {
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"a\n", "x\na\n"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"c", "C"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"end\n"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"b\nC", "B\nc"}}}},
+	}}
+	report := &Report{}
+	Apply("a.txt", []byte("a\nb\nc\n"), tr, Options{Report: report})
+
+	expected := [][]LineRange{{{1, 1}}, {{3, 3}}, {{3, 3}}, {{2, 3}}}
+	for i, transf := range report.Files()[0].Transformations {
+		if !reflect.DeepEqual(transf.Changes, expected[i]) {
+			t.Errorf("Transformation %v should change the original lines %v but found %v", i+1, expected[i], transf.Changes)
+		}
+	}
+
+	script := "def transform(file, content):\n    return content.upper()"
+	tr.Transformations = append(tr.Transformations, Transformation{Filter: "*.txt",
+		Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"x", "y", "a", "b"}}, Procedure{Name: "Script", Params: []string{script}}}})
+	report = &Report{}
+	Apply("a.txt", []byte("a\nb\nc\n"), tr, Options{Report: report})
+	var replacements []int
+	for _, transf := range report.Files()[0].Transformations {
+		replacements = append(replacements, transf.Replacements)
+	}
+	// The script doesn't count its replacements, it counts 1 after the 2
+	// replacements of Replace
+	if !reflect.DeepEqual(replacements, []int{1, 1, 1, 1, 3}) {
+		t.Errorf("The replacements should be counted but found %v", replacements)
+	}
+
+	tr.Transformations[1].Proc = []Procedure{Procedure{Name: "Unknown"}}
+	report = &Report{}
+	Apply("a.txt", []byte("a\nb\nc\n"), tr, Options{Report: report})
+	failed := report.Files()[0].Transformations
+	if len(failed) != 2 || failed[0].Error != "" || failed[1].Error != "unknown procedure" {
+		t.Errorf("The error should be reported on the failing transformation but found %+v", failed)
+	}
+}
+
+func TestReportInterleavedChanges(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 1; i <= 30; i++ {
+		switch i {
+		case 3, 26:
+			buf.WriteString("foo\n")
+		case 15:
+			buf.WriteString("bar\n")
+		default:
+			fmt.Fprintf(&buf, "line %v\n", i)
+		}
+	}
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"foo", "FOO"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"bar", "BAR"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"line 10\n", "", "BAR\n", "BAR\nnew\n"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"new", "NEW", "line 11", "eleven"}}}},
+	}}
+	report := &Report{}
+	Apply("a.txt", buf.Bytes(), tr, Options{Report: report})
+
+	// The inserted line is located before the line following it
+	expected := [][]LineRange{{{3, 3}, {26, 26}}, {{15, 15}}, {{10, 10}, {16, 16}}, {{11, 11}, {16, 16}}}
+	for i, transf := range report.Files()[0].Transformations {
+		if !reflect.DeepEqual(transf.Changes, expected[i]) {
+			t.Errorf("Transformation %v should change the original lines %v but found %v", i+1, expected[i], transf.Changes)
+		}
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	for _, c := range []struct {
+		before, after string
+		expected      lineEdit
+	}{
+		{"a\nb\nc\n", "a\nB\nc\n", lineEdit{1, 2, 2}},
+		{"a\nb\n", "a\nb\nc\n", lineEdit{2, 2, 3}},
+		{"a\nb\n", "x\na\nb\n", lineEdit{0, 0, 1}},
+		{"a", "ab", lineEdit{0, 1, 1}},
+		{"a\nbx", "a\nbyx", lineEdit{1, 2, 2}},
+		{"a\na\n", "a\n", lineEdit{1, 2, 1}},
+	} {
+		if res := changedLines([]byte(c.before), []byte(c.after)); res != c.expected {
+			t.Errorf("%q changed into %q should replace the lines %v but found %v", c.before, c.after, c.expected, res)
+		}
+	}
+}
+
+// TestReportLargeFile checks that the lines of a large file changed by
+// several transformations are found with little memory.
+func TestReportLargeFile(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&buf, "line %v\r\n", i)
+	}
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"\r\n", "\n"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"line 4999", "last"}}}},
+		Transformation{Filter: "*.txt", Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"end\n"}}}},
+	}}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	report := &Report{}
+	Apply("a.txt", buf.Bytes(), tr, Options{Report: report})
+	runtime.ReadMemStats(&after)
+
+	file := report.Files()[0]
+	expected := [][]LineRange{{{1, 5000}}, {{5000, 5000}}, {{5000, 5000}}}
+	for i, transf := range file.Transformations {
+		if !reflect.DeepEqual(transf.Changes, expected[i]) {
+			t.Errorf("Transformation %v should change the original lines %v but found %v", i+1, expected[i], transf.Changes)
+		}
+	}
+	if file.LinesAdded != 5001 || file.LinesRemoved != 5000 {
+		t.Errorf("The lines of the file should be counted but found %+v", file)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("The report should allocate little memory but allocated %v bytes", allocated)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+)
+
+// Hunk is a group of close changes made by a transformation on a file,
+// submitted to Options.Review.
+type Hunk struct {
+	// OldStart and NewStart are the first lines of the hunk in the content
+	// before and after the transformation, starting at 1
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Diff is the hunk in the unified diff format
+	Diff string
+}
+
+// review submits the changes of the transformation at the given position
+// to opts.Review and returns the content with only the accepted hunks.
+func review(filePath string, index int, t Transformation, before, after []byte, opts Options) []byte {
+	ops := diffLines(splitLines(before), splitLines(after))
+	hunks := diffHunks(ops, diffContext)
+	if len(hunks) == 0 {
+		return after
+	}
+	submitted := make([]Hunk, len(hunks))
+	for i, h := range hunks {
+		submitted[i] = Hunk{OldStart: h.OldStart, OldLines: h.OldLines, NewStart: h.NewStart, NewLines: h.NewLines,
+			Diff: h.String()}
+	}
+
+	accepted := opts.Review(filePath, index, t, submitted)
+	var buf bytes.Buffer
+	h := 0
+	for i, op := range ops {
+		for h < len(hunks) && hunks[h].last < i {
+			h++
+		}
+		apply := h < len(hunks) && hunks[h].first <= i && h < len(accepted) && accepted[h]
+		switch {
+		case op.Kind == ' ', op.Kind == '+' && apply, op.Kind == '-' && !apply:
+			buf.WriteString(op.Line)
+		}
+	}
+	return buf.Bytes()
+}
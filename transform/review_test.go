@@ -0,0 +1,92 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReview(t *testing.T) {
+	content := "import javax.inject;\n" + strings.Repeat("\n", 10) + "@javax.Named\nclass App {}\n"
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "*.java", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"javax", "jakarta"}}}},
+		Transformation{Filter: "*.java", Once: true, Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"class", "final class"}}}},
+	}}
+
+	var reviewed []Hunk
+	opts := Options{Once: NewOnceState(""), Review: func(file string, index int, transf Transformation, hunks []Hunk) []bool {
+		if file != "App.java" || transf.Filter != "*.java" {
+			t.Errorf("The review should receive the file and its transformation but found %s, %+v", file, transf)
+		}
+		if index == 2 {
+			return []bool{false}
+		}
+		reviewed = hunks
+		return []bool{false, true}
+	}}
+	res, err := Apply("App.java", []byte(content), tr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "import javax.inject;\n" + strings.Repeat("\n", 10) + "@jakarta.Named\nclass App {}\n"
+	if string(res) != expected {
+		t.Errorf("Only the accepted hunk should be applied but found %q", res)
+	}
+	if len(reviewed) != 2 || reviewed[1].OldStart != 9 || reviewed[1].OldLines != 5 ||
+		!strings.Contains(reviewed[1].Diff, "@@ -9,5 +9,5 @@\n \n \n \n-@javax.Named\n+@jakarta.Named\n class App {}\n") {
+		t.Errorf("The changes should be reviewed as hunks with context but found %+v", reviewed)
+	}
+	if opts.Once.has(tr.Transformations[1], "App.java") {
+		t.Error("A rejected transformation marked as once should be proposed again")
+	}
+}
+
+func TestReviewAfterVerboseMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seed-review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, name := range []string{"A.java", "B.java", "C.java"} {
+		files = append(files, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(files[len(files)-1], []byte("import javax.inject;\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := T{Transformations: []Transformation{
+		Transformation{Name: "jakarta", Filter: "*.java", Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"javax", "jakarta"}}}},
+	}}
+
+	var out bytes.Buffer
+	opts := Options{Jobs: 4, Verbose: true, Out: &out, Review: func(file string, index int, transf Transformation, hunks []Hunk) []bool {
+		if !strings.HasSuffix(out.String(), "Apply transformation jakarta to "+ShortPath(file)+"\n") {
+			t.Errorf("The messages of %s should be written before its review but found %q", file, out.String())
+		}
+		out.WriteString("Review " + ShortPath(file) + "\n")
+		return []bool{true}
+	}}
+	res := Fix(files, tr, opts)
+	if len(res.Files) != 3 || len(res.Errors) != 0 {
+		t.Errorf("The reviewed files should be fixed but found %+v", res)
+	}
+	for _, f := range files {
+		expected := "Check file " + ShortPath(f) + "\nApply transformation jakarta to " + ShortPath(f) +
+			"\nReview " + ShortPath(f) + "\nUpdated file " + ShortPath(f) + "\n"
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("The messages and the review of %s should be in order but found %q", f, out.String())
+		}
+	}
+}
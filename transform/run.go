@@ -0,0 +1,138 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Options configures a run of the transformations.
+type Options struct {
+	// Jobs is the number of files transformed concurrently, 1 if not set
+	Jobs int
+	// Verbose and VeryVerbose enable the messages written to Out,
+	// VeryVerbose implies Verbose
+	Verbose     bool
+	VeryVerbose bool
+	// Out receives the messages of the run, they are discarded if nil
+	Out io.Writer
+	// GitIgnore skips the files ignored by git when walking a git work tree
+	GitIgnore bool
+	// Once records the files already transformed by the transformations
+	// marked as "once". They are applied on every run if it is nil.
+	Once *OnceState
+	// WriteFile writes the modified files, by default with ioutil.WriteFile
+	WriteFile func(path string, data []byte) error
+	// Timeout limits the duration of the commands run by the procedures,
+	// like the plugins, 0 for no limit
+	Timeout time.Duration
+	// Review is called with the hunks of each transformation modifying a
+	// file and returns the accepted ones, the others are not applied. The
+	// index is the position of the transformation, starting at 1. The
+	// reviewed files are transformed one at a time and their verbose
+	// messages are written before the review.
+	Review func(file string, index int, t Transformation, hunks []Hunk) []bool
+	// Only restricts the run to the transformations with one of these
+	// names or tags, and Skip skips them
+	Only []string
+	Skip []string
+	// Index restricts the run to the transformation at this position,
+	// starting at 1, if it is set
+	Index int
+	// Report collects the details of the transformations of each file,
+	// if it is not nil
+	Report *Report
+}
+
+func (o Options) out() io.Writer {
+	if o.Out == nil {
+		return ioutil.Discard
+	}
+	return o.Out
+}
+
+func (o Options) verbose() bool {
+	return o.Verbose || o.VeryVerbose
+}
+
+func (o Options) writeFile(path string, data []byte) error {
+	if o.WriteFile == nil {
+		return ioutil.WriteFile(path, data, 0644)
+	}
+	return o.WriteFile(path, data)
+}
+
+// Result is the outcome of a run on a list of files.
+type Result struct {
+	// Files are the files modified by Fix, the files to fix found by Check
+	// or the files not idempotent found by Verify, in the order of the list
+	Files []string
+	// Errors are the failures of the files left unmodified, the other
+	// files are still transformed
+	Errors []error
+}
+
+// Fix applies the transformations on the files and writes the modified ones.
+func Fix(files []string, t T, opts Options) Result {
+	var res Result
+	res.Files, res.Errors = runFiles(files, opts, func(filePath string, out io.Writer) (bool, error) {
+		if opts.verbose() {
+			fmt.Fprintf(out, "Check file %s\n", ShortPath(filePath))
+		}
+
+		origDat, data, err := processFile(filePath, t, opts, out)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(origDat, data) {
+			if opts.VeryVerbose {
+				fmt.Fprintf(out, "No update for %s\n", filePath)
+			}
+			return false, nil
+		}
+
+		if err := opts.writeFile(filePath, data); err != nil {
+			return false, &TransformError{File: filePath, Err: err}
+		}
+		if opts.verbose() {
+			fmt.Fprintf(out, "Updated file %s\n", ShortPath(filePath))
+		}
+		return true, nil
+	})
+
+	if opts.VeryVerbose {
+		fmt.Fprintf(opts.out(), "---\n\nChecked %v files\n\n", len(files))
+	}
+	return res
+}
+
+// Check returns the files which would be modified by the transformations,
+// without writing them.
+func Check(files []string, t T, opts Options) Result {
+	var res Result
+	res.Files, res.Errors = runFiles(files, opts, func(filePath string, out io.Writer) (bool, error) {
+		if opts.verbose() {
+			fmt.Fprintf(out, "Check file %s\n", ShortPath(filePath))
+		}
+		origDat, data, err := processFile(filePath, t, opts, out)
+		return err == nil && !bytes.Equal(origDat, data), err
+	})
+	return res
+}
+
+// Apply applies the transformations matching the file name on the given
+// content and returns the transformed content. Nothing is read or written.
+// If a transformation fails, a *TransformError is returned.
+func Apply(filePath string, data []byte, t T, opts Options) ([]byte, error) {
+	return transformData(filePath, data, t, opts, opts.out())
+}
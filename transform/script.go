@@ -0,0 +1,144 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"sync"
+	"time"
+)
+
+// scriptFunction is the function defined by the scripts of the Script
+// procedure.
+const scriptFunction = "transform"
+
+// scripts caches the compiled scripts by source.
+var scripts sync.Map
+
+func init() {
+	RegisterProcedure(ProcedureDef{
+		Name: "Script",
+		Description: "Transforms the file with a Starlark script, a dialect of Python. The script defines " +
+			"a \"transform(file, content)\" function returning the new content, or None to keep the file " +
+			"unchanged. The scripts have no access to the filesystem or the network, they are stopped " +
+			"after the timeout set with -timeout and their print calls are shown in very verbose mode.",
+		Params: []Param{{Name: "script", Description: "the source of the script"}},
+		Examples: []string{"proc:\n  - name: Script\n    params:\n      - |\n" +
+			"        def transform(file, content):\n" +
+			"            if file.endswith(\"Test.java\"):\n" +
+			"                return None\n" +
+			"            return content.replace(\"javax.\", \"jakarta.\")"},
+		Validate: func(params []string) error {
+			_, err := compileScript(params[0])
+			return err
+		},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			return runScript(params[0], data, env)
+		},
+	})
+
+	RegisterPrecondition(PreconditionDef{
+		Name: "Script",
+		Description: "Evaluates a Starlark expression with the \"file\" and \"content\" variables, the " +
+			"transformation is applied if it is true. Quote the precondition in YAML if it contains \": \".",
+		Arg:      &Param{Name: "expression", Description: "the expression to evaluate"},
+		Examples: []string{"pre:\n  - Script(\"javax.\" in content and not file.endswith(\"Test.java\"))"},
+		Validate: func(arg string) error {
+			_, err := syntax.ParseExpr("pre", arg, 0)
+			return err
+		},
+		Check: func(data []byte, arg string, env Env) (bool, error) {
+			thread, stop := newScriptThread(env)
+			defer stop()
+			vars := starlark.StringDict{"file": starlark.String(env.File), "content": starlark.String(data)}
+			v, err := starlark.Eval(thread, "pre", arg, vars)
+			if err != nil {
+				return false, scriptError(err)
+			}
+			return bool(v.Truth()), nil
+		},
+	})
+}
+
+// compileScript compiles the script of the Script procedure, it is only
+// compiled once.
+func compileScript(src string) (*starlark.Program, error) {
+	if prog, ok := scripts.Load(src); ok {
+		return prog.(*starlark.Program), nil
+	}
+	_, prog, err := starlark.SourceProgram("script", src, func(string) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	scripts.Store(src, prog)
+	return prog, nil
+}
+
+// newScriptThread returns a thread which can't load other scripts and
+// prints to env.Out. It is cancelled after env.Timeout until stop is called.
+func newScriptThread(env Env) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: env.File,
+		Print: func(_ *starlark.Thread, msg string) {
+			if env.Out != nil {
+				fmt.Fprintln(env.Out, msg)
+			}
+		},
+	}
+	if env.Timeout <= 0 {
+		return thread, func() {}
+	}
+	timer := time.AfterFunc(env.Timeout, func() {
+		thread.Cancel(fmt.Sprintf("timed out after %s", env.Timeout))
+	})
+	return thread, func() { timer.Stop() }
+}
+
+// runScript runs the "transform" function of the script on the data.
+func runScript(src string, data []byte, env Env) ([]byte, error) {
+	prog, err := compileScript(src)
+	if err != nil {
+		return nil, err
+	}
+	thread, stop := newScriptThread(env)
+	defer stop()
+
+	globals, err := prog.Init(thread, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	fn, ok := globals[scriptFunction].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("the script doesn't define a %s(file, content) function", scriptFunction)
+	}
+	res, err := starlark.Call(thread, fn, starlark.Tuple{starlark.String(env.File), starlark.String(data)}, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	switch res := res.(type) {
+	case starlark.NoneType:
+		return data, nil
+	case starlark.String:
+		return []byte(res), nil
+	default:
+		return nil, fmt.Errorf("%s should return a string or None but returned a %s", scriptFunction, res.Type())
+	}
+}
+
+// scriptError returns the error of a script with its backtrace.
+func scriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var jakartaScript = `
+def transform(file, content):
+    if file.endswith("Test.java"):
+        return None
+    return content.replace("javax.", "jakarta.")
+`
+
+func applyScript(file, content, pre, script string) (string, error) {
+	tr := T{Transformations: []Transformation{Transformation{
+		Filter: "*.java",
+		Pre:    []string{pre},
+		Proc:   []Procedure{Procedure{Name: "Script", Params: []string{script}}},
+	}}}
+	res, err := Apply(file, []byte(content), tr, Options{Timeout: time.Second})
+	return string(res), err
+}
+
+func TestScript(t *testing.T) {
+	for _, c := range []struct {
+		file, content, pre, expected string
+	}{
+		{"App.java", "import javax.inject;", "AlwaysTrue", "import jakarta.inject;"},
+		{"AppTest.java", "import javax.inject;", "AlwaysTrue", "import javax.inject;"},
+		{"App.java", "import javax.inject;", `Script("inject" in content)`, "import jakarta.inject;"},
+		{"App.java", "import javax.inject;", `Script(file.startswith("Test"))`, "import javax.inject;"},
+	} {
+		res, err := applyScript(c.file, c.content, c.pre, jakartaScript)
+		if res != c.expected || err != nil {
+			t.Errorf("%s with %s should be transformed to %q but found %q, %v", c.file, c.pre, c.expected, res, err)
+		}
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	for _, c := range []struct {
+		pre, script, expected string
+	}{
+		{"AlwaysTrue", "x = 1", "doesn't define a transform(file, content) function"},
+		{"AlwaysTrue", "def transform(file, content):\n    return 1", "should return a string or None but returned a int"},
+		{"AlwaysTrue", "def transform(file, content):\n    fail(\"unsupported\")", "unsupported"},
+		{"AlwaysTrue", "def transform(file, content):\n    return open(file).read()", "undefined: open"},
+		{"AlwaysTrue", "def transform(file, content):\n    for i in range(1000000000):\n        pass", "timed out after 1s"},
+		{"Script(unknown)", jakartaScript, "Script: pre:1:1: undefined: unknown"},
+		{"AlwaysTrue(1)", jakartaScript, "AlwaysTrue: takes no argument"},
+		{"Script", jakartaScript, "Script: expects an argument, written Script(expression)"},
+	} {
+		_, err := applyScript("App.java", "", c.pre, c.script)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("The script should fail with %q but found %v", c.expected, err)
+		}
+	}
+}
+
+func TestValidateScript(t *testing.T) {
+	def, _ := LookupProcedure("Script")
+	if err := def.checkParams([]string{jakartaScript}); err != nil {
+		t.Errorf("The script should be valid but found %v", err)
+	}
+	if err := def.checkParams([]string{"def transform(:"}); err == nil {
+		t.Error("A syntax error should be reported")
+	}
+
+	pre, _ := LookupPrecondition("Script")
+	if err := pre.checkArg(`"a" in content`, true); err != nil {
+		t.Errorf("The expression should be valid but found %v", err)
+	}
+	if err := pre.checkArg(`"a" in`, true); err == nil {
+		t.Error("A syntax error should be reported")
+	}
+}
+
+func TestSplitPrecondition(t *testing.T) {
+	for pre, expected := range map[string][]string{
+		"AlwaysTrue":              {"AlwaysTrue", ""},
+		" Script(f(a) and b) ":    {"Script", "f(a) and b"},
+		"Script()":                {"Script", ""},
+		"(x)":                     {"(x)", ""},
+		"Script(\n  a or\n  b\n)": {"Script", "\n  a or\n  b\n"},
+	} {
+		name, arg, _ := splitPrecondition(pre)
+		if name != expected[0] || arg != expected[1] {
+			t.Errorf("%q should be split in %q but found %q, %q", pre, expected, name, arg)
+		}
+	}
+}
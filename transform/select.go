@@ -0,0 +1,59 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+// Selects returns true if the transformation is run with the options: it
+// has one of the names or tags of Only, if it is set, and none of Skip.
+func (o Options) Selects(t Transformation) bool {
+	if len(o.Only) > 0 && !labeled(t, o.Only) {
+		return false
+	}
+	return !labeled(t, o.Skip)
+}
+
+// selectsAt returns true if the transformation at the index, starting at
+// 1, is run with the options.
+func (o Options) selectsAt(index int, t Transformation) bool {
+	return (o.Index == 0 || o.Index == index) && o.Selects(t)
+}
+
+// UnknownSelections returns the names and tags of the Only and Skip
+// options which select no transformation, which are probably misspelled.
+func UnknownSelections(t T, opts Options) []string {
+	var unknown []string
+	for _, label := range append(append([]string{}, opts.Only...), opts.Skip...) {
+		found := false
+		for _, transf := range t.Transformations {
+			if labeled(transf, []string{label}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, label)
+		}
+	}
+	return unknown
+}
+
+// labeled returns true if the transformation has one of the labels as
+// name or tag.
+func labeled(t Transformation, labels []string) bool {
+	for _, label := range labels {
+		if label == t.Name && label != "" {
+			return true
+		}
+		for _, tag := range t.Tags {
+			if label == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+var labeledTdf = T{Transformations: []Transformation{
+	Transformation{Name: "footer", Tags: []string{"license"}, Filter: "*.txt",
+		Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"\n// end"}}}},
+	Transformation{Name: "rename", Tags: []string{"api", "upgrade"}, Filter: "*.txt",
+		Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"old", "new"}}}},
+	Transformation{Filter: "*.txt",
+		Proc: []Procedure{Procedure{Name: "Replace", Params: []string{"foo", "bar"}}}},
+}}
+
+func TestSelects(t *testing.T) {
+	cases := []struct {
+		only, skip []string
+		expected   string
+	}{
+		{nil, nil, "new bar\n// end"},
+		{[]string{"footer"}, nil, "old foo\n// end"},
+		{[]string{"upgrade"}, nil, "new foo"},
+		{[]string{"license", "rename"}, nil, "new foo\n// end"},
+		{nil, []string{"api"}, "old bar\n// end"},
+		{[]string{"license", "api"}, []string{"footer"}, "new foo"},
+	}
+	for _, c := range cases {
+		res, err := Apply("a.txt", []byte("old foo"), labeledTdf, Options{Only: c.only, Skip: c.skip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res) != c.expected {
+			t.Errorf("Only %v and skip %v should give %q but found %q", c.only, c.skip, c.expected, res)
+		}
+	}
+}
+
+func TestSelectIndex(t *testing.T) {
+	var indexes []int
+	opts := Options{Index: 3, Review: func(file string, index int, transf Transformation, hunks []Hunk) []bool {
+		indexes = append(indexes, index)
+		return []bool{true}
+	}}
+	res, err := Apply("a.txt", []byte("old foo"), labeledTdf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != "old bar" || !reflect.DeepEqual(indexes, []int{3}) {
+		t.Errorf("Only the third transformation should be applied but found %q, %v", res, indexes)
+	}
+	if res, _ = Apply("a.txt", []byte("old foo"), labeledTdf, Options{Index: 2, Skip: []string{"api"}}); string(res) != "old foo" {
+		t.Errorf("The skipped transformation should not be applied but found %q", res)
+	}
+}
+
+func TestUnknownSelections(t *testing.T) {
+	unknown := UnknownSelections(labeledTdf, Options{Only: []string{"footer", "upgrad"}, Skip: []string{"api", "Footer"}})
+	if !reflect.DeepEqual(unknown, []string{"upgrad", "Footer"}) {
+		t.Errorf("The misspelled names and tags should be unknown but found %v", unknown)
+	}
+}
+
+func TestErrorName(t *testing.T) {
+	tdf := T{Transformations: []Transformation{Transformation{Name: "broken", Filter: "*.txt",
+		Proc: []Procedure{Procedure{Name: "Unknown"}}}}}
+	_, err := Apply("a.txt", []byte("a"), tdf, Options{})
+	if err == nil || err.Error() != `a.txt: transformation 1 (broken): Unknown: unknown procedure` {
+		t.Errorf("The error should name the transformation but found %v", err)
+	}
+}
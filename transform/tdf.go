@@ -0,0 +1,157 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// StateDir is the directory where seed keeps its state in a project.
+// It is never transformed.
+const StateDir = ".seed"
+
+// T correspond to the content of a transformation file.
+// It contains exclude directories and an array of transformations.
+// The empty fields are omitted when the file is encoded, so it is parsed
+// back identically.
+type T struct {
+	Include         []string          `yaml:"include,omitempty" toml:"include,omitempty" json:"include,omitempty"`
+	Exclude         string            `yaml:"exclude,omitempty" toml:"exclude,omitempty" json:"exclude,omitempty"`
+	Vars            map[string]string `yaml:"vars,omitempty" toml:"vars,omitempty" json:"vars,omitempty"`
+	Transformations []Transformation  `yaml:"transformations,omitempty" toml:"transformations,omitempty" json:"transformations,omitempty"`
+	// Post are the commands run once after the transformations
+	Post []PostCommand `yaml:"post,omitempty" toml:"post,omitempty" json:"post,omitempty"`
+
+	// contents are the transformation files read by Load, by path, to
+	// locate the problems found by Validate
+	contents map[string][]byte
+}
+
+// Transformation is a strutucture representating a set
+// of procedure to apply on a source code directory
+type Transformation struct {
+	// Name identifies the transformation in the messages and for -only
+	// and -skip, it is optional
+	Name        string `yaml:"name,omitempty" toml:"name,omitempty" json:"name,omitempty"`
+	Description string `yaml:"description,omitempty" toml:"description,omitempty" json:"description,omitempty"`
+	// Tags select groups of transformations with -only and -skip
+	Tags   []string    `yaml:"tags,omitempty" toml:"tags,omitempty" json:"tags,omitempty"`
+	Filter string      `yaml:"filter" toml:"filter" json:"filter"`
+	Pre    []string    `yaml:"pre,omitempty" toml:"pre,omitempty" json:"pre,omitempty"`
+	Proc   []Procedure `yaml:"proc,omitempty" toml:"proc,omitempty" json:"proc,omitempty"`
+	// Once marks a transformation which is applied only once on each file
+	Once bool `yaml:"once,omitempty" toml:"once,omitempty" json:"once,omitempty"`
+	// Source is the transformation file declaring the transformation
+	Source string `yaml:"-" toml:"-" json:"-"`
+}
+
+// Procedure is a function call with a method name and
+// its parameters
+type Procedure struct {
+	Name   string   `yaml:"name" toml:"name" json:"name"`
+	Params []string `yaml:"params,omitempty" toml:"params,omitempty" json:"params,omitempty"`
+}
+
+// Format returns the format of a transformation file from its extension,
+// "yml", "toml" or "json".
+func Format(name string) (string, error) {
+	index := strings.LastIndex(name, ".") + 1
+	extension := strings.ToLower(name[index:])
+
+	var ext string
+	var err error
+
+	switch extension {
+	case "yml", "yaml":
+		ext = "yml"
+	case "toml":
+		ext = "toml"
+	case "json":
+		ext = "json"
+	default:
+		err = fmt.Errorf("%s format unsupported", extension)
+	}
+
+	return ext, err
+}
+
+// Parse parses the content of a transformation file in the given format.
+// The includes are not resolved, use Load to read a complete file.
+func Parse(dat []byte, format string) (T, error) {
+	var t T
+
+	switch format {
+	case "yml":
+		if err := yaml.Unmarshal(dat, &t); err != nil {
+			return T{}, fmt.Errorf("failed to parse the yaml file: %s", err)
+		}
+	case "toml":
+		if err := toml.Unmarshal(dat, &t); err != nil {
+			return T{}, fmt.Errorf("failed to parse the toml file: %s", err)
+		}
+	case "json":
+		if err := json.Unmarshal(dat, &t); err != nil {
+			return T{}, fmt.Errorf("failed to parse the json file: %s", err)
+		}
+	default:
+		return T{}, fmt.Errorf("%s format unsupported", format)
+	}
+	return t, nil
+}
+
+// Encode returns the content of a transformation file in the given format,
+// which is parsed back as the same transformations.
+func Encode(t T, format string) ([]byte, error) {
+	switch format {
+	case "yml":
+		return yaml.Marshal(t)
+	case "toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(t); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		dat, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(dat, '\n'), nil
+	default:
+		return nil, fmt.Errorf("%s format unsupported", format)
+	}
+}
+
+func fetchURL(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return nil, fmt.Errorf("error %v when fetching %s", resp.StatusCode, url)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func readFile(path string) ([]byte, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(absPath)
+}
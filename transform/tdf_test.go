@@ -0,0 +1,234 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var tdfYml = `exclude: "*.out"
+transformations:
+ - 
+  filter: "*.go|*.yml"
+  pre: 
+   - AlwaysTrue
+  proc:
+   - 
+    name: Replace
+    params:
+     - "old"
+     - "new"
+ - 
+  filter: "*.java"
+  pre: 
+   - AlwaysTrue
+  proc:
+   - name: DoNothing
+`
+
+func TestParseTdf(t *testing.T) {
+	tr, err := Parse([]byte(tdfYml), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Exclude != "*.out" {
+		t.Error("The file should contains exclude directories.")
+	}
+	if len(tr.Transformations) != 2 {
+		t.Error("The tfl file should contains two transformations.")
+	}
+
+	tranf := tr.Transformations[0]
+
+	if tranf.Filter != "*.go|*.yml" {
+		t.Error("The first transformation should contains include files.")
+	}
+	if tranf.Pre[0] != "AlwaysTrue" {
+		t.Error("The first transformation should contains a precondition.")
+	}
+	if len(tranf.Proc) != 1 || tranf.Proc[0].Name != "Replace" || tranf.Proc[0].Params[0] != "old" {
+		t.Error("The first transformation should contains a 'replace' procedure.")
+	}
+}
+
+var tdfToml = `exclude= "*.out"
+
+[[transformations]]
+  filter = "*.go|*.yml"
+  pre = [ "AlwaysTrue" ]
+
+  [[transformations.proc]]
+   name = "Replace"
+   params = [ "old", "new" ]
+
+[[transformations]]
+  filter = "*.java"
+  pre = [ "AlwaysTrue" ]
+
+  [[transformations.proc]]
+    name = "DoNothing"
+`
+
+func TestParseTdfWithToml(t *testing.T) {
+	tr, err := Parse([]byte(tdfToml), "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Exclude != "*.out" {
+		t.Error("The file should contains exclude directories.")
+	}
+	if len(tr.Transformations) != 2 {
+		t.Error("The tfl file should contains two transformations.")
+	}
+
+	tranf := tr.Transformations[0]
+
+	if tranf.Filter != "*.go|*.yml" {
+		t.Error("The first transformation should contains include files.")
+	}
+	if tranf.Pre[0] != "AlwaysTrue" {
+		t.Error("The first transformation should contains a precondition.")
+	}
+	if len(tranf.Proc) != 1 || tranf.Proc[0].Name != "Replace" || tranf.Proc[0].Params[0] != "old" {
+		t.Errorf("The first transformation should contains a 'Replace' procedure.\n%v", tr)
+	}
+}
+
+func TestGetFormat(t *testing.T) {
+	ext, err := Format("my/path.yml")
+	if err != nil || ext != "yml" {
+		t.Errorf("yml was expected but found %s, %v", ext, err)
+	}
+
+	ext, err = Format("my/path.yaml")
+	if err != nil || ext != "yml" {
+		t.Errorf("yaml was expected but found %s, %v", ext, err)
+	}
+
+	ext, err = Format("my/path.toml")
+	if err != nil || ext != "toml" {
+		t.Errorf("tomml was expected but found %s, %v", ext, err)
+	}
+
+	ext, err = Format("my/path.TOML")
+	if err != nil || ext != "toml" {
+		t.Errorf("TOML was expected but found %s, %v", ext, err)
+	}
+
+	ext, err = Format("my/path.json")
+	if err != nil || ext != "json" {
+		t.Errorf("json was expected but found %s, %v", ext, err)
+	}
+
+	if _, err := Format("my/path.fancy"); err == nil {
+		t.Errorf("unsupported format error was expected, but found: %s", err)
+	}
+}
+
+var fullTdfYml = `include:
+  - "base.yml"
+exclude: "target|.git"
+vars:
+  version: "15.4"
+  group: "org.mycompany"
+transformations:
+  - name: upgrade
+    description: "Upgrade the dependency"
+    tags: [maven, upgrade]
+    filter: "pom.xml"
+    once: true
+    pre:
+      - Script("<dependencies>" in content)
+    proc:
+      - name: ReplaceMavenDependency
+        params: ["${group}:app:*", "${group}:app:${version}"]
+  - filter: "*.java"
+    proc:
+      - name: Script
+        params:
+          - |
+            def transform(file, content):
+                return content.replace("\"a\"", 'b\tc')
+      - name: Insert
+        params: [""]
+post:
+  - filter: "*.java"
+    command: ["google-java-format", "--replace"]
+  - command: ["mvn", "-q", "validate"]
+`
+
+func TestEncodeRoundTrip(t *testing.T) {
+	expected, err := Parse([]byte(fullTdfYml), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, formats := range [][]string{
+		{"yml"}, {"toml"}, {"json"},
+		{"toml", "json", "yml"}, {"json", "toml", "yml"},
+	} {
+		tr := expected
+		for _, format := range formats {
+			dat, err := Encode(tr, format)
+			if err != nil {
+				t.Fatalf("Failed to encode in %s: %s", format, err)
+			}
+			if tr, err = Parse(dat, format); err != nil {
+				t.Fatalf("Failed to parse the %s encoding: %s\n%s", format, err, dat)
+			}
+		}
+		if !reflect.DeepEqual(tr, expected) {
+			t.Errorf("The conversion to %v should keep the transformations\nexpected %+v\nfound    %+v", formats, expected, tr)
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, k := range strings.Fields("include exclude vars version group transformations name description tags filter once pre proc params post command") {
+		keys[k] = true
+	}
+	for _, format := range []string{"yml", "toml", "json"} {
+		dat, err := Encode(expected, format)
+		if err != nil {
+			t.Fatalf("Failed to encode in %s: %s", format, err)
+		}
+		if found := encodedKeys(dat, format); !reflect.DeepEqual(found, keys) {
+			t.Errorf("The %s encoding should use the lowercase keys but found %v\n%s", format, found, dat)
+		}
+	}
+
+	if _, err := Encode(expected, "xml"); err == nil {
+		t.Error("The xml format should be unsupported")
+	}
+}
+
+var keyPatterns = map[string]*regexp.Regexp{
+	"yml":  regexp.MustCompile(`(?m)^[ -]*(\w+):`),
+	"toml": regexp.MustCompile(`(?m)^\s*(?:(\w+) =|\[+(?:\w+\.)*(\w+)\]+$)`),
+	"json": regexp.MustCompile(`"(\w+)":`),
+}
+
+// encodedKeys returns the keys of the encoded transformation file.
+func encodedKeys(dat []byte, format string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, m := range keyPatterns[format].FindAllStringSubmatch(string(dat), -1) {
+		keys[strings.Join(m[1:], "")] = true
+	}
+	return keys
+}
+
+func TestReadFile(t *testing.T) {
+	if bytes, err := readFile("../test/tdf.yml"); bytes == nil || err != nil {
+		t.Error("ReadFile: Failed to read ./test/conf.yml")
+	}
+
+}
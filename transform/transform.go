@@ -0,0 +1,396 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Procedures regroup the built-in procedures, which are registered
+// in the procedure registry.
+type Procedures struct {
+	// out receives the very verbose messages of the procedures
+	out io.Writer
+	// env counts the replacements of the procedures
+	env Env
+}
+
+func init() {
+	RegisterPrecondition(PreconditionDef{
+		Name:        "AlwaysTrue",
+		Description: "Always true, the transformation is applied on all the files matching the filter.",
+		Examples:    []string{"pre:\n  - AlwaysTrue"},
+		Check: func(data []byte, arg string, env Env) (bool, error) {
+			return true, nil
+		},
+	})
+
+	RegisterProcedure(ProcedureDef{
+		Name:        "Insert",
+		Description: "Inserts a string at the end of the file.",
+		Params:      []Param{{Name: "string", Description: "the string to insert"}},
+		Examples:    []string{"proc:\n  - name: Insert\n    params: [\"endOfFile\"]"},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			p := Procedures{out: env.Out, env: env}
+			return p.Insert(data, params[0]), nil
+		},
+	})
+	RegisterProcedure(ProcedureDef{
+		Name: "EnsureInsert",
+		Description: "Inserts a string at the end of the file only if the file doesn't already contain it. " +
+			"Unlike Insert, it can be run several times on the same file.",
+		Params:   []Param{{Name: "string", Description: "the string to insert"}},
+		Examples: []string{"proc:\n  - name: EnsureInsert\n    params: [\"endOfFile\"]"},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			p := Procedures{out: env.Out, env: env}
+			return p.EnsureInsert(data, params[0]), nil
+		},
+	})
+	RegisterProcedure(ProcedureDef{
+		Name:        "RemoveAtEnd",
+		Description: "Removes the given number of bytes, the length of the string, at the end of the file.",
+		Params:      []Param{{Name: "string", Description: "the string whose length is removed"}},
+		Examples:    []string{"proc:\n  - name: RemoveAtEnd\n    params: [\"endOfFile\"]"},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			if len(params[0]) > len(data) {
+				return nil, fmt.Errorf("the file is shorter than %q", params[0])
+			}
+			p := Procedures{out: env.Out, env: env}
+			return p.RemoveAtEnd(data, params[0]), nil
+		},
+	})
+	RegisterProcedure(ProcedureDef{
+		Name:        "Replace",
+		Description: "Replaces all the occurrences of strings by new ones. The replacements are applied in order.",
+		Params:      []Param{{Name: "pairs", Description: "pairs of an old string and its replacement", Variadic: true}},
+		Examples:    []string{"proc:\n  - name: Replace\n    params:\n      - \"myStringToModify\"\n      - \"myModifiedString\""},
+		Validate:    validatePairs,
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			p := Procedures{out: env.Out, env: env}
+			return p.Replace(data, params...), nil
+		},
+	})
+	RegisterProcedure(ProcedureDef{
+		Name: "ReplaceMavenDependency",
+		Description: "Replaces Maven dependencies in a POM file. The dependencies are written as " +
+			"\"groupId:artifactId\" to replace the groupId and the artifactId, \"groupId:artifactId:version\" " +
+			"to also replace the version, even when it is a property of the same file, or " +
+			"\"groupId:artifactId:*\" followed by \"groupId:artifactId\" to remove the version.",
+		Params: []Param{{Name: "pairs", Description: "pairs of an old dependency and its replacement", Variadic: true}},
+		Examples: []string{"proc:\n  - name: ReplaceMavenDependency\n    params:\n" +
+			"      - \"org.mycompany:myApp1\"\n      - \"com.mycompany:myApp2\""},
+		Validate: func(params []string) error {
+			if err := validatePairs(params); err != nil {
+				return err
+			}
+			for i := 0; i < len(params); i += 2 {
+				if _, err := matchDependency("", params[i], params[i+1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			p := Procedures{out: env.Out, env: env}
+			return p.ReplaceMavenDependency(data, params...)
+		},
+	})
+}
+
+func validatePairs(params []string) error {
+	if len(params) == 0 || len(params)%2 != 0 {
+		return fmt.Errorf("expects pairs of parameters but found %v parameters", len(params))
+	}
+	return nil
+}
+
+func checkFileName(fileName string, tr Transformation) (bool, error) {
+	matched := false
+	// Include files
+	for _, patt := range strings.Split(tr.Filter, "|") {
+		res, err := filepath.Match(patt, filepath.Base(fileName))
+		if err != nil {
+			return false, fmt.Errorf("invalid filter %s: %s", tr.Filter, err)
+		}
+		matched = res || matched
+	}
+	return matched, nil
+}
+
+// checkCondition returns true if all the preconditions of the
+// transformation are verified. A failing precondition returns a
+// *TransformError with its name. The results are added to rep if it is
+// not nil.
+func checkCondition(data []byte, t Transformation, env Env, rep *TransformationReport) (bool, error) {
+	ok := true
+	for _, pre := range t.Pre {
+		name, arg, hasArg := splitPrecondition(pre)
+		def, found := LookupPrecondition(name)
+		if !found {
+			return false, &TransformError{Procedure: name, Err: errors.New("unknown precondition")}
+		}
+		var err error
+		if ok, err = def.check(data, arg, hasArg, env); err != nil {
+			return false, &TransformError{Procedure: name, Err: err}
+		}
+		rep.precondition(pre, ok)
+		if !ok {
+			break
+		}
+	}
+	return ok, nil
+}
+
+// applyProcs applies the procedures of the transformation on the data.
+// A failing procedure returns a *TransformError with its name. The
+// procedures are added to rep if it is not nil.
+func applyProcs(data []byte, t Transformation, env Env, rep *TransformationReport) ([]byte, error) {
+	for _, proc := range t.Proc {
+		def, found := LookupProcedure(proc.Name)
+		if !found {
+			return nil, &TransformError{Procedure: proc.Name, Err: errors.New("unknown procedure")}
+		}
+		procEnv := env
+		replaced := -1
+		if rep != nil {
+			procEnv.replaced = func(n int) {
+				if replaced < 0 {
+					replaced = 0
+				}
+				replaced += n
+			}
+		}
+		res, err := def.apply(data, proc.Params, procEnv)
+		if err != nil {
+			return nil, &TransformError{Procedure: proc.Name, Err: err}
+		}
+		rep.procedure(proc.Name, data, res, replaced)
+		data = res
+	}
+	return data, nil
+}
+
+// -----------------
+
+// Insert the string s at the end of the given data.
+//
+// proc:
+//  -
+//    name: Insert
+//    params: "endOfFile"
+func (p *Procedures) Insert(dat []byte, s string) []byte {
+	p.replaced(1)
+	return append(dat, []byte(s)...)
+}
+
+// EnsureInsert inserts the string s at the end of the given data
+// only if s is not already present. Unlike Insert, it can be run
+// several times on the same file.
+//
+// proc:
+//  -
+//    name: EnsureInsert
+//    params: "endOfFile"
+func (p *Procedures) EnsureInsert(dat []byte, s string) []byte {
+	if bytes.Contains(dat, []byte(s)) {
+		return dat
+	}
+	p.replaced(1)
+	return append(dat, []byte(s)...)
+}
+
+func (p *Procedures) RemoveAtEnd(dat []byte, s string) []byte {
+	p.replaced(1)
+	return dat[:len(dat)-len([]byte(s))]
+}
+
+// replaced records the replacements of a procedure.
+func (p *Procedures) replaced(n int) {
+	if p != nil {
+		p.env.Replaced(n)
+	}
+}
+
+// Replace the old string by the new one. You can use it as follows in your transformation file.
+//
+// proc:
+//  -
+//    name: Replace
+//    params:
+//      - "myStringToModify"
+//      - "myModifiedString"
+//      # After you can add other pairs
+//      - "x"
+//      - "y"
+//      ...
+func (p *Procedures) Replace(dat []byte, pairs ...string) []byte {
+	new := dat
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i] != pairs[i+1] {
+			p.replaced(strings.Count(string(new), pairs[i]))
+		}
+		new = []byte(strings.Replace(string(new), pairs[i], pairs[i+1], -1))
+		if p != nil && p.out != nil && bytes.Compare(new, dat) != 0 {
+			fmt.Fprintf(p.out, "\t%s -> %s\n", pairs[i], pairs[i+1])
+		}
+	}
+
+	return new
+}
+
+// ReplaceMavenDependency replaces a maven dependency by a new one.
+// The dependency to update are passed as pairs. For instance you want to update the following dependency:
+//
+// <dependency>
+//   <groupId>org.mycompany</groupId>
+//   <artifactId>myApp1</artifactId>
+// </dependency>
+//
+// Call the ReplaceMavenDependency with a pair of the old dependency and the new one.
+//
+// proc:
+//  -
+//    name: ReplaceMavenDependency
+//    params:
+//      - "org.mycompany:myApp1"
+//      - "com.mycompany:myApp2"
+//      # After you can add other pairs
+//      - "xx:xx"
+//      - "yy:yy"
+//      ...
+//
+// Accepted formats for dependencies
+//
+// Replace groupId and artifactId:
+//  - "xx:xx"
+//  - "yy:yy
+//
+// Replace groupId, artifactId and version:
+//  - "xx:xx:xx"
+//  - "xx:xx:xx"
+//
+// NB: It also includes the where the version is specify
+// as a property in the same file.
+//
+// Replace groupId and artifactId and remove the version:
+//  - "xx:xx:*"
+//  - "yy:yy
+//
+func (p *Procedures) ReplaceMavenDependency(data []byte, pairs ...string) ([]byte, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("expects pairs of dependencies but found %v parameters", len(pairs))
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		pom, err := matchDependency(string(data), pairs[i], pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(pom)
+	}
+	return data, nil
+}
+
+func matchDependency(pom, old, new string) (string, error) {
+	currentDep := strings.Split(old, ":")
+	newDep := strings.Split(new, ":")
+
+	var res string
+	var err error
+	switch {
+	case len(currentDep) == 2 && len(newDep) == 2:
+		depRegex := regexp.MustCompile("(<groupId>)" + currentDep[0] + "(<\\/groupId>.*?\\n.*?" +
+			"<artifactId>)" + currentDep[1] + "(<\\/artifactId>)")
+
+		res = depRegex.ReplaceAllString(pom, "${1}"+newDep[0]+"${2}"+newDep[1]+"${3}")
+
+	case len(currentDep) == 3 && len(newDep) == 3:
+		res, err = matchDependencyWithVersion(pom, old, new)
+
+	case len(currentDep) == 3 && currentDep[2] == "*" && len(newDep) == 2:
+		res, err = matchDependencyAndRemoveVersion(pom, old, new)
+
+	default:
+		err = fmt.Errorf(`the expected formats for dependencies are "xx:xx", "xx:xx:xx" or "xx:xx:*" `+
+			`but found "%s" and "%s"`, old, new)
+	}
+	return res, err
+}
+
+func matchDependencyWithVersion(pom, old, new string) (string, error) {
+	currentDep := strings.Split(old, ":")
+	newDep := strings.Split(new, ":")
+
+	if len(currentDep) != 3 || len(newDep) != 3 {
+		return "", fmt.Errorf(`expects the format "groupId:artifactId:version" but found "%s" and "%s"`, old, new)
+	}
+
+	regex := "(<groupId>)" + regexp.QuoteMeta(currentDep[0]) + "(<\\/groupId>.*?\\n.*?" +
+		"<artifactId>)" + regexp.QuoteMeta(currentDep[1]) + "(<\\/artifactId>.*?\\n.*?" +
+		"<version>)(.*?)(<\\/version>)"
+
+	depRegex := regexp.MustCompile(regex)
+
+	propsDefinition := regexp.MustCompile("\\$\\{(.*?)\\}")
+	
+	submatch := depRegex.FindStringSubmatch(pom)
+	if len(submatch) == 6 {
+		// Find the version of the dependency to replace
+		version := submatch[4]
+		// Check if it is a property
+		match := propsDefinition.FindStringSubmatch(version)
+
+		var props string
+		if match != nil {
+			props = match[1]
+		}
+
+		if props != "" {
+			propsToReplace := regexp.MustCompile("(<" + regexp.QuoteMeta(props) + ">).*?(</" + regexp.QuoteMeta(props) + ">)")
+			pom = propsToReplace.ReplaceAllString(pom, "${1}"+newDep[2]+"${2}")
+			return depRegex.ReplaceAllString(pom, "${1}"+newDep[0]+"${2}"+newDep[1]+"${3}"+"${4}"+"${5}"), nil
+		}
+
+		return depRegex.ReplaceAllString(pom, "${1}"+newDep[0]+"${2}"+newDep[1]+"${3}"+newDep[2]+"${5}"), nil
+		
+	} else {
+		// The dependency was not found. Do nothing
+		return pom, nil
+	}
+}
+
+func matchDependencyAndRemoveVersion(pom, old, new string) (string, error) {
+	currentDep := strings.Split(old, ":")
+	newDep := strings.Split(new, ":")
+
+	if len(currentDep) != 3 || len(newDep) != 2 {
+		return "", fmt.Errorf(`expects the formats "groupId:artifactId:*" and "groupId:artifactId" but found "%s" and "%s"`, old, new)
+	}
+
+	regex := "(<groupId>)" + currentDep[0] + "(<\\/groupId>.*?\\n.*?" +
+		"<artifactId>)" + currentDep[1] + "(<\\/artifactId>.*?)\\n.*?" +
+		"<version>.*?<\\/version>"
+
+	depRegexWithVersion := regexp.MustCompile(regex)
+	if depRegexWithVersion.FindString(pom) != "" {
+		return depRegexWithVersion.ReplaceAllString(pom, "${1}"+newDep[0]+"${2}"+newDep[1]+"${3}"), nil
+	}
+
+	regex = "(<groupId>)" + currentDep[0] + "(<\\/groupId>.*?\\n.*?" +
+		"<artifactId>)" + currentDep[1] + "(<\\/artifactId>)"
+
+	depRegex := regexp.MustCompile(regex)
+
+	return depRegex.ReplaceAllString(pom, "${1}"+newDep[0]+"${2}"+newDep[1]+"${3}"), nil
+}
@@ -6,10 +6,15 @@
 // License, v. 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
-package main
+package transform
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -17,20 +22,76 @@ func TestPrecondition(t *testing.T) {
 	tt := Transformation{Pre: []string{"AlwaysTrue"}}
 	tf := Transformation{Pre: []string{"AlwaysFalse"}}
 
-	if !checkCondition("", []byte{}, tt) {
+	if ok, err := checkCondition([]byte{}, tt, Env{}, nil); !ok || err != nil {
 		t.Error("Precondition should be always true")
 	}
-	if checkCondition("", []byte{}, tf) {
+	if ok, err := checkCondition([]byte{}, tf, Env{}, nil); ok || err != nil {
 		t.Error("Precondition should be always false")
 	}
+	_, err := checkCondition([]byte{}, Transformation{Pre: []string{"Unknown"}}, Env{}, nil)
+	if e, ok := err.(*TransformError); !ok || e.Procedure != "Unknown" {
+		t.Errorf("An unknown precondition should fail but found %v", err)
+	}
+
+}
+
+func TestUnwrapTransformError(t *testing.T) {
+	err := error(withLocation(fmt.Errorf("plugin: %w", context.DeadlineExceeded), "a.txt", T{Transformations: []Transformation{{}}}, 0))
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("The cause of the error should be found but found %v", err)
+	}
+
+	var invalid *ValidationError
+	err = &TransformError{File: "a.txt", Err: &ValidationError{Source: "tdf.yml", Msg: "invalid"}}
+	if !errors.As(err, &invalid) || invalid.Source != "tdf.yml" {
+		t.Errorf("The validation error should be found but found %v", err)
+	}
+}
 
+func TestTransformErrorSource(t *testing.T) {
+	replace := []Procedure{Procedure{Name: "Replace", Params: []string{"a", "b"}}}
+	tdf := T{Transformations: []Transformation{
+		Transformation{Filter: "*.txt", Source: "base.yml", Proc: replace},
+		Transformation{Filter: "*.txt", Source: "tdf.yml", Proc: replace},
+		Transformation{Filter: "*.txt", Source: "base.yml", Proc: replace},
+		Transformation{Name: "broken", Filter: "*.txt", Source: "tdf.yml", Proc: []Procedure{Procedure{Name: "Unknown"}}},
+	}}
+	report := &Report{}
+	_, err := Apply("a.txt", []byte("a"), tdf, Options{Report: report})
+	var transfErr *TransformError
+	if !errors.As(err, &transfErr) || transfErr.Source != "tdf.yml" || transfErr.Transformation != 2 ||
+		err.Error() != "a.txt: tdf.yml: transformation 2 (broken): Unknown: unknown procedure" {
+		t.Errorf("The error should be located in the file declaring the transformation but found %v", err)
+	}
+	if trs := report.Files()[0].Transformations; trs[3].Index != 4 || trs[3].Error != "unknown procedure" || trs[1].Error != "" {
+		t.Errorf("The error should be reported on the failing transformation but found %+v", trs)
+	}
 }
 
-func (c *Conditions) AlwaysFalse(fileName string, data []byte) bool {
-	return false
+func init() {
+	RegisterPrecondition(PreconditionDef{
+		Name: "AlwaysFalse",
+		Check: func(data []byte, arg string, env Env) (bool, error) {
+			return false, nil
+		},
+	})
+	RegisterProcedure(ProcedureDef{
+		Name: "DoNothing",
+		Apply: func(data []byte, params []string, env Env) ([]byte, error) {
+			return data, nil
+		},
+	})
 }
 
 func TestFile(t *testing.T) {
+	checkFileName := func(fileName string, tr Transformation) bool {
+		matched, err := checkFileName(fileName, tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matched
+	}
+
 	tg := Transformation{Filter: "*.go"}
 	tgy := Transformation{Filter: "*.go|*.yml"}
 	matched := checkFileName("test\\src\\bla\\bla\\cmd.go", tg) &&
@@ -51,20 +112,36 @@ func TestProcedures(t *testing.T) {
 	tn := Transformation{Proc: []Procedure{Procedure{Name: "DoNothing"}}}
 	ti := Transformation{Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"bar"}}}}
 
-	res := applyProcs([]byte("foo"), tn)
-	if string(res) != "foo" {
-		t.Errorf("Procedure should do nothing, %s was expected but found %s", "foo", res)
+	res, err := applyProcs([]byte("foo"), tn, Env{Out: ioutil.Discard}, nil)
+	if string(res) != "foo" || err != nil {
+		t.Errorf("Procedure should do nothing, %s was expected but found %s, %v", "foo", res, err)
 	}
 
-	res = applyProcs([]byte("foo"), ti)
-	if string(res) != "foobar" {
-		t.Errorf("Procedure should insert bar, %s was expected but found %s", "foobar", res)
+	res, err = applyProcs([]byte("foo"), ti, Env{Out: ioutil.Discard}, nil)
+	if string(res) != "foobar" || err != nil {
+		t.Errorf("Procedure should insert bar, %s was expected but found %s, %v", "foobar", res, err)
 	}
 
 }
 
-func (p *Procedures) DoNothing(dat []byte) []byte {
-	return dat
+func TestProcedureErrors(t *testing.T) {
+	cases := []struct {
+		proc     Procedure
+		expected string
+	}{
+		{Procedure{Name: "Unknown"}, "unknown procedure"},
+		{Procedure{Name: "Insert"}, "expects 1 parameters but found 0"},
+		{Procedure{Name: "Replace", Params: []string{"foo"}}, "expects pairs of parameters"},
+		{Procedure{Name: "RemoveAtEnd", Params: []string{"foobar"}}, "the file is shorter than"},
+		{Procedure{Name: "ReplaceMavenDependency", Params: []string{"a:b", "c"}}, "the expected formats for dependencies"},
+	}
+	for _, c := range cases {
+		_, err := applyProcs([]byte("foo"), Transformation{Proc: []Procedure{c.proc}}, Env{Out: ioutil.Discard}, nil)
+		e, ok := err.(*TransformError)
+		if !ok || e.Procedure != c.proc.Name || !strings.Contains(e.Error(), c.expected) {
+			t.Errorf("%s should fail with %q but found %v", c.proc.Name, c.expected, err)
+		}
+	}
 }
 
 func TestReplace(t *testing.T) {
@@ -90,10 +167,24 @@ func TestInsertAndRemove(t *testing.T) {
 	}
 }
 
+func TestEnsureInsert(t *testing.T) {
+	var p *Procedures
+
+	inc := p.EnsureInsert([]byte("foo"), "bar")
+	if string(inc) != "foobar" {
+		t.Errorf("EnsureInsert: %s was expected but found %s", "foobar", inc)
+	}
+
+	inc = p.EnsureInsert(inc, "bar")
+	if string(inc) != "foobar" {
+		t.Errorf("EnsureInsert: %s was expected but found %s", "foobar", inc)
+	}
+}
+
 func TestReplaceMavenDependency(t *testing.T) {
 	var p *Procedures
-	news := string(p.ReplaceMavenDependency([]byte(pom), "com.inetpsa.fnd:seed-bom", "org.seedstack:bom", "org.seedstack:bom", "org.seedstack:seedstack-bom"))
-	if news != expectedPom {
+	news, err := p.ReplaceMavenDependency([]byte(pom), "com.inetpsa.fnd:seed-bom", "org.seedstack:bom", "org.seedstack:bom", "org.seedstack:seedstack-bom")
+	if string(news) != expectedPom || err != nil {
 		t.Errorf("Procedure should replace 'com.inetpsa.fnd:seed-bom' with 'org.seedstack:seedstack-bom' but found:\n %s", news)
 	}
 }
@@ -134,7 +225,10 @@ func TestMatchDependency(t *testing.T) {
 	old := "com.inetpsa.fnd:seed-bom"
 	new := "org.seedstack:seedstack-bom"
 
-	result := matchDependency(pom, old, new)
+	result, err := matchDependency(pom, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if result != expectedPom {
 		fmt.Println("found:\n" + result)
 		t.Error("Fail to replace maven dependency")
@@ -145,9 +239,12 @@ func TestMatchInexistentDependency(t *testing.T) {
 	old := "com.inetpsa.fnd:seedbom:zzz"
 	new := "org.seedstack:seedstack-bom:yyy"
 
-	result := matchDependency(pom, old, new)
+	result, err := matchDependency(pom, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if result != pom {
-		t.Error("Don't update pom when the dep doesn't match:\n - orig\n%s- updated\n%s", pom, result)
+		t.Errorf("Don't update pom when the dep doesn't match:\n - orig\n%s- updated\n%s", pom, result)
 	}
 }
 
@@ -171,7 +268,10 @@ func TestMatchDependencyWithVersion(t *testing.T) {
 	old := "com.inetpsa.fnd:seed-bom:14.11"
 	new := "org.seedstack:seedstack-bom:15.4-M2-SNAPSHOT"
 
-	result := matchDependency(pom, old, new)
+	result, err := matchDependency(pom, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if result != expectedPomWithVersion {
 		fmt.Println("found:\n" + result)
 		t.Error("Fail to replace maven dependency with version")
@@ -180,8 +280,8 @@ func TestMatchDependencyWithVersion(t *testing.T) {
 
 func TestReplaceMavenDependencyWithVersion(t *testing.T) {
 	var p *Procedures
-	news := string(p.ReplaceMavenDependency([]byte(pom), "com.inetpsa.fnd:seed-bom:14.11", "org.seedstack:bom:15.4", "org.seedstack:bom:15.4", "org.seedstack:seedstack-bom:15.4-M2-SNAPSHOT"))
-	if news != expectedPomWithVersion {
+	news, err := p.ReplaceMavenDependency([]byte(pom), "com.inetpsa.fnd:seed-bom:14.11", "org.seedstack:bom:15.4", "org.seedstack:bom:15.4", "org.seedstack:seedstack-bom:15.4-M2-SNAPSHOT")
+	if string(news) != expectedPomWithVersion || err != nil {
 		t.Errorf("Procedure should replace 'com.inetpsa.fnd:seed-bom:14.11' with 'org.seedstack:seedstack-bom:15.4-M2-SNAPSHOT' but found:\n %s", news)
 	}
 }
@@ -230,7 +330,10 @@ func TestMatchDependencyWithVersionAndProps(t *testing.T) {
 	old := "com.inetpsa.fnd:seed-bom:14.11"
 	new := "org.seedstack:seedstack-bom:15.4-M2-SNAPSHOT"
 
-	result := matchDependency(pomWithProperty, old, new)
+	result, err := matchDependency(pomWithProperty, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if result != expectedPomWithProperty {
 		fmt.Println("found:\n" + result)
 		t.Error("Fail to replace maven dependency with version")
@@ -256,7 +359,10 @@ func TestMatchDependencyWithRemovedVersion(t *testing.T) {
 	old := "com.inetpsa.fnd:seed-bom:*"
 	new := "org.seedstack:seedstack-bom"
 
-	result := matchDependency(pom, old, new)
+	result, err := matchDependency(pom, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if result != expectedPomWithRemovedVersion {
 		fmt.Println("found:\n" + result)
 		t.Error("Fail to replace maven dependency and removing its version")
@@ -0,0 +1,305 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"fmt"
+	yaml3 "gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+)
+
+// ValidationError is a problem of a transformation file found before
+// transforming any file.
+type ValidationError struct {
+	// Source is the transformation file declaring the invalid element
+	Source string
+	// Line is the line of the invalid element, or 0 if it is unknown
+	Line int
+	// Transformation is the position of the invalid transformation in its
+	// file, starting at 1, or 0 if the problem is not in a transformation
+	Transformation int
+	// Name is the name of the invalid transformation, if it has one
+	Name string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Source
+	if e.Line > 0 {
+		msg += fmt.Sprintf(":%v", e.Line)
+	}
+	msg += transformationName(e.Transformation, e.Name)
+	return fmt.Sprintf("%s: %s", msg, e.Msg)
+}
+
+// Validate checks the transformations before they are applied: the
+// patterns must be valid, the preconditions and procedures must exist and
+// the procedures must have the expected number of parameters. It returns
+// all the problems found, located in the transformation files when possible:
+// the lines are given in the YAML files read by Load.
+func Validate(t T, path string) []error {
+	var errs []error
+	lines := make(map[string]*tdfLines)
+	linesOf := func(source string) *tdfLines {
+		if _, ok := lines[source]; !ok {
+			lines[source] = readTdfLines(source, t.contents[source])
+		}
+		return lines[source]
+	}
+
+	for _, patt := range strings.Split(t.Exclude, "|") {
+		if _, err := filepath.Match(patt, ""); err != nil {
+			errs = append(errs, &ValidationError{Source: path, Line: linesOf(path).exclude,
+				Msg: fmt.Sprintf("invalid exclude pattern %s: %s", patt, err)})
+		}
+	}
+
+	positions := make(map[string]int)
+	names := make(map[string]bool)
+	for _, transf := range t.Transformations {
+		source := transf.Source
+		if source == "" {
+			source = path
+		}
+		index := positions[source]
+		positions[source]++
+		l := linesOf(source).transformation(index)
+		invalid := func(line int, format string, args ...interface{}) {
+			errs = append(errs, &ValidationError{Source: source, Line: line, Transformation: index + 1, Name: transf.Name,
+				Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if transf.Name != "" {
+			if names[transf.Name] {
+				invalid(l.line, "duplicate name %q", transf.Name)
+			}
+			names[transf.Name] = true
+		}
+		for _, label := range append([]string{transf.Name}, transf.Tags...) {
+			if strings.Contains(label, ",") {
+				invalid(l.line, "the name or tag %q can't contain a comma", label)
+			}
+		}
+
+		if transf.Filter == "" {
+			invalid(l.line, "missing filter")
+		}
+		for _, patt := range strings.Split(transf.Filter, "|") {
+			if _, err := filepath.Match(patt, ""); err != nil {
+				invalid(l.filterLine(), "invalid filter %s: %s", transf.Filter, err)
+			}
+		}
+
+		for i, pre := range transf.Pre {
+			name, arg, hasArg := splitPrecondition(pre)
+			def, ok := LookupPrecondition(name)
+			if !ok {
+				invalid(l.preLine(i), "unknown precondition %q", name)
+				continue
+			}
+			if err := def.checkArg(arg, hasArg); err != nil {
+				invalid(l.preLine(i), "%s %s", name, err)
+			}
+		}
+
+		if len(transf.Proc) == 0 {
+			invalid(l.line, "no procedure to apply")
+		}
+		for i, proc := range transf.Proc {
+			def, ok := LookupProcedure(proc.Name)
+			if !ok {
+				invalid(l.procLine(i, false), "unknown procedure %q", proc.Name)
+				continue
+			}
+			if err := validateParams(def, proc.Params); err != nil {
+				invalid(l.procLine(i, true), "%s %s", proc.Name, err)
+			}
+		}
+	}
+
+	positions = make(map[string]int)
+	for _, post := range t.Post {
+		source := post.Source
+		if source == "" {
+			source = path
+		}
+		positions[source]++
+		index := positions[source]
+		invalid := func(format string, args ...interface{}) {
+			errs = append(errs, &ValidationError{Source: source,
+				Msg: fmt.Sprintf("post command %v: %s", index, fmt.Sprintf(format, args...))})
+		}
+		if len(post.Command) == 0 {
+			invalid("missing command")
+		} else if err := lookCommand(post.Command[0]); err != nil {
+			invalid("%s", err)
+		}
+		for _, patt := range strings.Split(post.Filter, "|") {
+			if _, err := filepath.Match(patt, ""); err != nil {
+				invalid("invalid filter %s: %s", post.Filter, err)
+			}
+		}
+	}
+	return errs
+}
+
+// validateParams checks the parameters of a procedure. Some parameters
+// are compiled as regular expressions, their panics are returned as errors.
+func validateParams(def ProcedureDef, params []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("has invalid parameters: %v", r)
+		}
+	}()
+	return def.checkParams(params)
+}
+
+// tdfLines gives the lines of the elements of a transformation file.
+// The lines are 0 when they are unknown.
+type tdfLines struct {
+	exclude         int
+	transformations []transformationLines
+}
+
+type transformationLines struct {
+	line   int
+	filter int
+	pre    []int
+	procs  []procLines
+}
+
+type procLines struct {
+	line   int
+	name   int
+	params int
+}
+
+func (l *tdfLines) transformation(i int) transformationLines {
+	if i < len(l.transformations) {
+		return l.transformations[i]
+	}
+	return transformationLines{}
+}
+
+func (l transformationLines) filterLine() int {
+	return firstLine(l.filter, l.line)
+}
+
+func (l transformationLines) preLine(i int) int {
+	if i < len(l.pre) {
+		return firstLine(l.pre[i], l.line)
+	}
+	return l.line
+}
+
+func (l transformationLines) procLine(i int, params bool) int {
+	if i >= len(l.procs) {
+		return l.line
+	}
+	proc := l.procs[i]
+	if params {
+		return firstLine(proc.params, proc.line, l.line)
+	}
+	return firstLine(proc.name, proc.line, l.line)
+}
+
+func firstLine(lines ...int) int {
+	for _, line := range lines {
+		if line > 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+// readTdfLines finds the lines of the elements of a transformation file from
+// its content. Only the YAML parser gives the positions of the elements, the
+// TOML parser only gives the line of syntax errors, so the problems of the
+// TOML and JSON files are only located by their transformation.
+func readTdfLines(path string, dat []byte) *tdfLines {
+	if format, _ := Format(path); format != "yml" || dat == nil {
+		return &tdfLines{}
+	}
+	return yamlLines(dat)
+}
+
+// yamlLines finds the lines of the elements of a YAML file from the nodes
+// of the YAML parser.
+func yamlLines(dat []byte) *tdfLines {
+	res := &tdfLines{}
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(dat, &doc); err != nil || len(doc.Content) == 0 {
+		return res
+	}
+	root := resolveAlias(doc.Content[0])
+	if key, _ := yamlKey(root, "exclude"); key != nil {
+		res.exclude = key.Line
+	}
+	_, transformations := yamlKey(root, "transformations")
+	for _, item := range yamlItems(transformations) {
+		transf := transformationLines{line: item.Line}
+		item = resolveAlias(item)
+		if key, _ := yamlKey(item, "filter"); key != nil {
+			transf.filter = key.Line
+		}
+		if key, pre := yamlKey(item, "pre"); key != nil {
+			for _, p := range yamlItems(pre) {
+				transf.pre = append(transf.pre, p.Line)
+			}
+			if len(transf.pre) == 0 {
+				transf.pre = []int{key.Line}
+			}
+		}
+		_, procs := yamlKey(item, "proc")
+		for _, p := range yamlItems(procs) {
+			proc := procLines{line: p.Line}
+			p = resolveAlias(p)
+			if key, _ := yamlKey(p, "name"); key != nil {
+				proc.name = key.Line
+			}
+			if key, _ := yamlKey(p, "params"); key != nil {
+				proc.params = key.Line
+			}
+			transf.procs = append(transf.procs, proc)
+		}
+		res.transformations = append(res.transformations, transf)
+	}
+	return res
+}
+
+// yamlKey returns the key and the value of a mapping node, or nil if the
+// node is not a mapping or doesn't have the key.
+func yamlKey(node *yaml3.Node, key string) (*yaml3.Node, *yaml3.Node) {
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil, nil
+}
+
+// yamlItems returns the items of a sequence node.
+func yamlItems(node *yaml3.Node) []*yaml3.Node {
+	if node == nil || node.Kind != yaml3.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// resolveAlias returns the node an alias refers to.
+func resolveAlias(node *yaml3.Node) *yaml3.Node {
+	for node != nil && node.Kind == yaml3.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
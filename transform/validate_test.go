@@ -0,0 +1,186 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var invalidYamlTdf = `include:
+  - "base.toml"
+exclude: "target|[.git"
+transformations:
+ -
+  filter: "pom.xml"
+  pre:
+    - AlwaysTrue
+    - Unknown
+  proc:
+    -
+      name: Replace
+      params:
+        - "old"
+    - name: Replac
+      params: ["old", "new"]
+ - filter: "*.java"
+   proc:
+    - name: ReplaceMavenDependency
+      params:
+        - "org.mycompany:myApp1"
+        - "org.mycompany"
+    - name: Insert
+      params: []
+`
+
+var invalidTomlTdf = `[[Transformations]]
+Filter = "[a-"
+
+  [[Transformations.Proc]]
+  Name = "EnsureInsert"
+  Params = ["foo"]
+
+[[Transformations]]
+Filter = "*.txt"
+`
+
+func validationErrors(t *testing.T, dir string) []string {
+	path := filepath.Join(dir, "tdf.yml")
+	transf, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, err := range Validate(transf, path) {
+		rel := strings.TrimPrefix(err.Error(), dir+string(filepath.Separator))
+		res = append(res, filepath.ToSlash(rel))
+	}
+	return res
+}
+
+func TestValidateTdf(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"tdf.yml": invalidYamlTdf, "base.toml": invalidTomlTdf})
+	defer os.RemoveAll(dir)
+
+	expected := []string{
+		"tdf.yml:3: invalid exclude pattern [.git: syntax error in pattern",
+		"base.toml: transformation 1: invalid filter [a-: syntax error in pattern",
+		"base.toml: transformation 2: no procedure to apply",
+		`tdf.yml:9: transformation 1: unknown precondition "Unknown"`,
+		"tdf.yml:13: transformation 1: Replace expects pairs of parameters but found 1 parameters",
+		`tdf.yml:15: transformation 1: unknown procedure "Replac"`,
+		`tdf.yml:20: transformation 2: ReplaceMavenDependency the expected formats for dependencies are "xx:xx", ` +
+			`"xx:xx:xx" or "xx:xx:*" but found "org.mycompany:myApp1" and "org.mycompany"`,
+		"tdf.yml:24: transformation 2: Insert expects 1 parameters but found 0",
+	}
+	errs := validationErrors(t, dir)
+	if strings.Join(errs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected validation errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func TestValidateValidTdf(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"tdf.yml": `transformations:
+  - filter: "*.txt|*.md"
+    pre: [AlwaysTrue]
+    proc:
+      - name: Replace
+        params: ["a", "b", "c", "d"]
+      - name: ReplaceMavenDependency
+        params: ["g:a:*", "g:b"]
+`})
+	defer os.RemoveAll(dir)
+
+	if errs := validationErrors(t, dir); len(errs) != 0 {
+		t.Errorf("The transformation file should be valid but found %v", errs)
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"tdf.yml": `transformations:
+  - name: license
+    filter: "*.java"
+    proc: [{name: Insert, params: ["// License"]}]
+  - name: license
+    tags: ["a,b"]
+    filter: "*.java"
+`})
+	defer os.RemoveAll(dir)
+
+	expected := []string{
+		`tdf.yml:5: transformation 2 (license): duplicate name "license"`,
+		`tdf.yml:5: transformation 2 (license): the name or tag "a,b" can't contain a comma`,
+		"tdf.yml:5: transformation 2 (license): no procedure to apply",
+	}
+	errs := validationErrors(t, dir)
+	if strings.Join(errs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected validation errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func TestValidateYamlLines(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"tdf.yml": `transformations:
+  - &base
+    filter: "*.java"
+    proc: [{name: Replac, params: ["a", "b"]}]
+  - filter: "*.txt"
+    pre:
+      - Unknown
+    proc:
+      - name: Script
+        params:
+          - |
+            def transform(file, content):
+                return content
+      - {name: Insert,
+         params: []}
+  - *base
+---
+transformations:
+  - filter: "*.md"
+`})
+	defer os.RemoveAll(dir)
+
+	expected := []string{
+		`tdf.yml:4: transformation 1: unknown procedure "Replac"`,
+		`tdf.yml:7: transformation 2: unknown precondition "Unknown"`,
+		"tdf.yml:15: transformation 2: Insert expects 1 parameters but found 0",
+		`tdf.yml:4: transformation 3: unknown procedure "Replac"`,
+	}
+	errs := validationErrors(t, dir)
+	if strings.Join(errs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected validation errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func TestValidateRemoteLines(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("transformations:\n  - filter: \"*.txt\"\n    proc:\n      - name: Replac\n"))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/tdf.yml"
+	transf, err := Load(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := Validate(transf, url)
+	if len(errs) != 1 || errs[0].Error() != url+`:4: transformation 1: unknown procedure "Replac"` {
+		t.Errorf("The remote file should be validated with its lines but found %v", errs)
+	}
+	if requests != 1 {
+		t.Errorf("The remote file should be fetched once but was fetched %v times", requests)
+	}
+}
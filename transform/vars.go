@@ -0,0 +1,132 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// varRegexp matches a variable reference like "${version}". A reference
+// prefixed by another "$" is escaped and kept as is, without the first "$".
+var varRegexp = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// MergeVars returns a new map with the variables of all the given maps.
+// The variables of the last maps override the ones of the first maps.
+func MergeVars(maps ...map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for _, m := range maps {
+		for key, value := range m {
+			vars[key] = value
+		}
+	}
+	return vars
+}
+
+// ReadVars reads the variables file at the given path. Like the
+// transformation file it can be written in YAML, TOML or JSON.
+func ReadVars(path string) (map[string]string, error) {
+	format, err := Format(path)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported format for %s", path)
+	}
+
+	dat, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	switch format {
+	case "yml":
+		err = yaml.Unmarshal(dat, &vars)
+	case "toml":
+		err = toml.Unmarshal(dat, &vars)
+	case "json":
+		err = json.Unmarshal(dat, &vars)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the variables file %s: %s", path, err)
+	}
+	return vars, nil
+}
+
+// interpolate replaces the variable references in s by their values.
+// The names of the variables which are not defined are added to undefined.
+func interpolate(s string, vars map[string]string, undefined map[string]bool) string {
+	return varRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := varRegexp.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok {
+			undefined[name] = true
+			return ref
+		}
+		return value
+	})
+}
+
+// Interpolate returns a copy of the transformations where the variables
+// used in the filters, the preconditions and the procedure parameters are
+// replaced by their values. It fails if one of the variables is undefined.
+func Interpolate(t T, vars map[string]string) (T, error) {
+	undefined := make(map[string]bool)
+	res := t
+	res.Transformations = make([]Transformation, len(t.Transformations))
+
+	for i, transf := range t.Transformations {
+		transf.Filter = interpolate(transf.Filter, vars, undefined)
+
+		pre := make([]string, len(transf.Pre))
+		for j, p := range transf.Pre {
+			pre[j] = interpolate(p, vars, undefined)
+		}
+		transf.Pre = pre
+
+		procs := make([]Procedure, len(transf.Proc))
+		for j, proc := range transf.Proc {
+			params := make([]string, len(proc.Params))
+			for k, param := range proc.Params {
+				params[k] = interpolate(param, vars, undefined)
+			}
+			proc.Params = params
+			procs[j] = proc
+		}
+		transf.Proc = procs
+
+		res.Transformations[i] = transf
+	}
+
+	res.Post = make([]PostCommand, len(t.Post))
+	for i, post := range t.Post {
+		post.Filter = interpolate(post.Filter, vars, undefined)
+		command := make([]string, len(post.Command))
+		for j, arg := range post.Command {
+			command[j] = interpolate(arg, vars, undefined)
+		}
+		post.Command = command
+		res.Post[i] = post
+	}
+
+	if len(undefined) > 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return t, fmt.Errorf("undefined variables: %s", strings.Join(names, ", "))
+	}
+	return res, nil
+}
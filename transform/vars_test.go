@@ -0,0 +1,100 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var tdfWithVars = `vars:
+  version: "15.4"
+  file: "pom.xml"
+transformations:
+ -
+  filter: "${file}|*.yml"
+  pre:
+   - AlwaysTrue
+  proc:
+   -
+    name: ReplaceMavenDependency
+    params:
+     - "org.seedstack:bom:*"
+     - "org.seedstack:seedstack-bom:${version}"
+   -
+    name: Replace
+    params:
+     - "$${project.version}"
+     - "${version}"
+`
+
+func TestInterpolateTdf(t *testing.T) {
+	tr, err := Parse([]byte(tdfWithVars), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Vars["version"] != "15.4" {
+		t.Errorf("The version variable should be 15.4 but found %s", tr.Vars["version"])
+	}
+
+	res, err := Interpolate(tr, MergeVars(tr.Vars, map[string]string{"version": "16.4"}))
+	if err != nil {
+		t.Fatalf("No error was expected but found %v", err)
+	}
+
+	transf := res.Transformations[0]
+	if transf.Filter != "pom.xml|*.yml" {
+		t.Errorf("The filter should be interpolated but found %s", transf.Filter)
+	}
+	if transf.Proc[0].Params[1] != "org.seedstack:seedstack-bom:16.4" {
+		t.Errorf("The overridden version should be used but found %s", transf.Proc[0].Params[1])
+	}
+	if transf.Proc[1].Params[0] != "${project.version}" {
+		t.Errorf("Escaped references should be kept but found %s", transf.Proc[1].Params[0])
+	}
+	if tr.Transformations[0].Proc[0].Params[1] != "org.seedstack:seedstack-bom:${version}" {
+		t.Error("The original transformations should not be modified")
+	}
+}
+
+func TestInterpolateUndefinedVars(t *testing.T) {
+	tr := T{Transformations: []Transformation{
+		Transformation{Filter: "${b}", Pre: []string{"${a}"}, Proc: []Procedure{Procedure{Name: "Insert", Params: []string{"${c}${a}"}}}},
+	}}
+
+	_, err := Interpolate(tr, map[string]string{"c": "foo"})
+	if err == nil || err.Error() != "undefined variables: a, b" {
+		t.Errorf("An error listing the undefined variables was expected but found %v", err)
+	}
+}
+
+func TestReadVars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seed-vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "vars.toml")
+	if err = ioutil.WriteFile(path, []byte(`version = "16.4"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fileVars, err := ReadVars(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := MergeVars(map[string]string{"version": "15.4", "file": "pom.xml"}, fileVars)
+	if vars["version"] != "16.4" || vars["file"] != "pom.xml" {
+		t.Errorf("The variables file should override the defaults but found %v", vars)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Walk returns the files of the root directory to transform, except the
+// transformation file itself, the excluded directories and the ignored
+// files. The files which can't be walked are returned as errors and skipped.
+func Walk(root string, excludes string, tdfPath string, opts Options) ([]string, []error) {
+	var files []string
+	var errs []error
+	for _, patt := range strings.Split(excludes, "|") {
+		if _, err := filepath.Match(patt, ""); err != nil {
+			return nil, []error{fmt.Errorf("invalid exclude pattern %s: %s", excludes, err)}
+		}
+	}
+	ignore, err := newIgnoreMatcher(root, opts.GitIgnore)
+	if err != nil {
+		return nil, []error{&TransformError{File: root, Err: err}}
+	}
+	if opts.VeryVerbose {
+		fmt.Fprintln(opts.out(), "Excluded packages:")
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs = append(errs, &TransformError{File: path, Err: err})
+			return nil
+		}
+		// Skip the files ignored by git or by a .seedignore file
+		if ignore.ignored(path, info.IsDir()) {
+			if !info.IsDir() {
+				return nil
+			}
+			if opts.VeryVerbose {
+				fmt.Fprintf(opts.out(), "\t%s\n", info.Name())
+			}
+			return filepath.SkipDir
+		}
+		if info.IsDir() {
+			// The state of seed is never transformed
+			if info.Name() == StateDir {
+				return filepath.SkipDir
+			}
+			// Global exclusion of directories
+			for _, patt := range strings.Split(excludes, "|") {
+				if match, _ := filepath.Match(patt, filepath.Base(path)); match {
+					if opts.VeryVerbose {
+						fmt.Fprintf(opts.out(), "\t%s\n", info.Name())
+					}
+					return filepath.SkipDir
+				}
+			}
+			if err := ignore.loadDir(path); err != nil {
+				errs = append(errs, &TransformError{File: path, Err: err})
+				return filepath.SkipDir
+			}
+		} else {
+			// Construct the list of files to scan
+			// but skip the transformation file if present
+			if info.Name() != filepath.Base(tdfPath) {
+				files = append(files, path)
+			}
+		}
+
+		return nil
+	})
+
+	if opts.VeryVerbose {
+		fmt.Fprintln(opts.out(), "---")
+	}
+
+	if err != nil {
+		errs = append(errs, &TransformError{File: root, Err: err})
+	}
+	return files, errs
+}
+
+// ShortPath returns the path relative to the current directory, to print
+// it in the messages, or the path itself if it can't be made relative.
+func ShortPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	relPath, err2 := filepath.Rel(wd, path)
+	if err2 != nil {
+		return path
+	}
+
+	return relPath
+}
+
+// fileResult is the result of a task run on a file by the worker pool.
+type fileResult struct {
+	index int
+	ok    bool
+	err   error
+	out   bytes.Buffer
+}
+
+// runFiles runs the task on the files with a pool of opts.Jobs workers and
+// returns the files for which the task returned true, and the errors of the
+// task, in the order of the list. The output of each task is buffered and
+// written to opts.Out in the order of the list too. The occurrences of a
+// file listed several times are run in order by the same worker, so a file
+// is never written concurrently. With opts.Review, a single worker writes
+// the output directly, so it is printed before the questions of the review,
+// and the output is only written by this worker.
+func runFiles(files []string, opts Options, task func(filePath string, out io.Writer) (bool, error)) ([]string, []error) {
+	var groups [][]int
+	groupOf := make(map[string]int)
+	for i, f := range files {
+		g, ok := groupOf[f]
+		if !ok {
+			g = len(groups)
+			groupOf[f] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	workers := opts.Jobs
+	if opts.Review != nil {
+		workers = 1
+	}
+	if workers > len(groups) {
+		workers = len(groups)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	pending := make(chan []int)
+	results := make(chan *fileResult)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for group := range pending {
+				for _, i := range group {
+					res := &fileResult{index: i}
+					var out io.Writer = &res.out
+					if opts.Review != nil {
+						out = opts.out()
+					}
+					res.ok, res.err = task(files[i], out)
+					results <- res
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, group := range groups {
+			pending <- group
+		}
+		close(pending)
+	}()
+
+	// Print the results as soon as the previous ones are printed
+	var matched []string
+	var errs []error
+	done := make([]*fileResult, len(files))
+	next := 0
+	for range files {
+		res := <-results
+		done[res.index] = res
+		for ; next < len(files) && done[next] != nil; next++ {
+			// The worker of a review writes the output itself
+			if opts.Review == nil {
+				opts.out().Write(done[next].out.Bytes())
+			}
+			if done[next].ok {
+				matched = append(matched, files[next])
+			}
+			if done[next].err != nil {
+				errs = append(errs, done[next].err)
+			}
+			done[next] = nil
+		}
+	}
+	return matched, errs
+}
+
+// processFile reads the file if a transformation matches its name and
+// returns its original and transformed content. The verbose messages are
+// written to out.
+func processFile(filePath string, t T, opts Options, out io.Writer) ([]byte, []byte, error) {
+	for i, transf := range t.Transformations {
+		if !opts.selectsAt(i+1, transf) {
+			continue
+		}
+		matched, err := checkFileName(filePath, transf)
+		if err != nil {
+			return nil, nil, withLocation(err, filePath, t, i)
+		}
+		if matched {
+			dat, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return nil, nil, &TransformError{File: filePath, Err: err}
+			}
+			data, err := transformData(filePath, dat, t, opts, out)
+			return dat, data, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// transformData applies the transformations matching the file name
+// on the given data and returns the transformed data. The verbose
+// messages are written to out. If a transformation fails, a
+// *TransformError is returned and the once markers are not recorded.
+func transformData(filePath string, data []byte, t T, opts Options, out io.Writer) (res []byte, err error) {
+	// The procedures only write very verbose messages
+	env := Env{File: filePath, Out: ioutil.Discard, Timeout: opts.Timeout}
+	if opts.VeryVerbose {
+		env.Out = out
+	}
+	rep := opts.Report.start(filePath, data)
+	defer func() { opts.Report.finish(rep, res, err) }()
+
+	var once []Transformation
+	for i, transf := range t.Transformations {
+		if !opts.selectsAt(i+1, transf) {
+			continue
+		}
+		matched, err := checkFileName(filePath, transf)
+		if err != nil {
+			return nil, withLocation(err, filePath, t, i)
+		}
+		if !matched {
+			continue
+		}
+		tr := rep.transformation(i+1, transf)
+
+		if transf.Once && opts.Once.has(transf, filePath) {
+			if tr != nil {
+				tr.AlreadyApplied = true
+			}
+			if opts.VeryVerbose {
+				fmt.Fprintf(out, "%s was already transformed once\n", filePath)
+			}
+			continue
+		}
+
+		// If preconditions matche then apply the transformations
+		started := time.Now()
+		ok, err := checkCondition(data, transf, env, tr)
+		if err != nil {
+			return nil, withLocation(err, filePath, t, i)
+		}
+		if ok {
+			if opts.verbose() && transf.Name != "" {
+				fmt.Fprintf(out, "Apply transformation %s to %s\n", transf.Name, ShortPath(filePath))
+			} else if opts.verbose() && transf.Source != "" {
+				fmt.Fprintf(out, "Apply transformation from %s to %s\n", transf.Source, ShortPath(filePath))
+			} else if opts.VeryVerbose {
+				fmt.Fprintf(out, "Apply tranformation to %s\n", filePath)
+			}
+			if tr != nil {
+				tr.Applied = true
+			}
+			before := data
+			data, err = applyProcs(data, transf, env, tr)
+			tr.timed(started)
+			if err != nil {
+				return nil, withLocation(err, filePath, t, i)
+			}
+			if opts.Review != nil && !bytes.Equal(before, data) {
+				if data = review(filePath, i+1, transf, before, data, opts); bytes.Equal(before, data) {
+					// The transformation is proposed again by the next run
+					continue
+				}
+			}
+			rep.edited(tr, before, data)
+			if transf.Once {
+				once = append(once, transf)
+			}
+		} else {
+			tr.timed(started)
+			if opts.VeryVerbose {
+				fmt.Fprintf(out, "%s doesn't match the preconditions\n", filePath)
+			}
+		}
+	}
+
+	for _, transf := range once {
+		opts.Once.add(transf, filePath)
+	}
+	return data, nil
+}
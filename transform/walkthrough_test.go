@@ -0,0 +1,192 @@
+// Copyright (c) 2013-2015 by The SeedStack authors. All rights reserved.
+
+// This file is part of SeedStack, An enterprise-oriented full development stack.
+
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const expectedCount = 6
+
+var expectedFile = filepath.FromSlash("../test/dir1/file21")
+
+func TestWalkDir(t *testing.T) {
+	files, errs := Walk("../test", "", "../test/tdf.yml", Options{GitIgnore: true})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(files) != expectedCount {
+		t.Errorf("WalkDir expect %v files but found %v", expectedCount, len(files))
+	}
+
+	if files[0] != expectedFile {
+		t.Errorf("WalkDir expect %v but found %v", expectedFile, files[0])
+	}
+
+	files, _ = Walk("../test", "test", "../test/tdf.yml", Options{GitIgnore: true})
+	if len(files) != 0 {
+		t.Errorf("WalkDir expect %v files but found %v", 0, len(files))
+	}
+}
+
+func readContent(t *testing.T, path string) string {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "<missing>"
+	}
+	return string(dat)
+}
+
+func TestShortPath(t *testing.T) {
+	originalPath := filepath.Join("..", "test", "dir1", "file21")
+	sp := ShortPath(expectedFile)
+
+	if sp != originalPath {
+		t.Errorf("shorpath: %s was expected but found %s", originalPath, sp)
+	}
+}
+
+func TestProcessFiles(t *testing.T) {
+	p := []Procedure{Procedure{Name: "Insert", Params: []string{"foo"}}}
+	tt := Transformation{Filter: "*file1", Proc: p}
+	tf := Transformation{Filter: "*.go", Proc: p}
+	filesToCheck := []string{"../test/file1", "../test/file1", "../test/file2"}
+	expectedCount := 2
+
+	modifiedFiles := Fix(filesToCheck, T{Transformations: []Transformation{tt, tf}}, Options{}).Files
+
+	if len(modifiedFiles) != expectedCount {
+		t.Errorf("Fix: %v files should be processed but found %v", expectedCount, len(modifiedFiles))
+	}
+
+	modifiedFiles = Fix(filesToCheck, T{Transformations: []Transformation{}}, Options{}).Files
+
+	if len(modifiedFiles) != 0 {
+		t.Errorf("Fix: no files should be processed but found %v", len(modifiedFiles))
+	}
+
+	// Cleanup
+	r := []Procedure{Procedure{Name: "RemoveAtEnd", Params: []string{"foo"}}}
+	cleanup := Transformation{Filter: "*file1", Proc: r}
+	filesToClean := []string{"../test/file1", "../test/file1"}
+	Fix(filesToClean, T{Transformations: []Transformation{cleanup}}, Options{})
+}
+
+func TestProcessFile(t *testing.T) {
+	p := []Procedure{Procedure{Name: "Insert", Params: []string{"foo"}}}
+	tt := Transformation{Filter: "*file1", Proc: p}
+	tf := Transformation{Filter: "*.go", Proc: p}
+
+	orig, dat, _ := processFile("../test/file1", T{Transformations: []Transformation{tt}}, Options{}, ioutil.Discard)
+	if string(orig) == string(dat) {
+		t.Error("file1 should be processed.")
+	}
+
+	orig, dat, _ = processFile("../test/file1", T{Transformations: []Transformation{tf}}, Options{}, ioutil.Discard)
+	if string(orig) != string(dat) {
+		t.Error("file1 should not be processed.")
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	p := []Procedure{Procedure{Name: "Insert", Params: []string{"foo"}}}
+	tt := Transformation{Filter: "*file1", Proc: p}
+
+	toFix := Check([]string{"../test/file1", "../test/file2"}, T{Transformations: []Transformation{tt}}, Options{}).Files
+	if len(toFix) != 1 || toFix[0] != "../test/file1" {
+		t.Errorf("Check: only file1 should be fixed but found %v", toFix)
+	}
+
+	orig, dat, _ := processFile("../test/file1", T{Transformations: []Transformation{Transformation{Filter: "*file1"}}}, Options{}, ioutil.Discard)
+	if string(orig) != string(dat) || len(orig) == 0 {
+		t.Error("Check should not modify file1.")
+	}
+}
+
+func TestProcessFilesConcurrently(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("dir%v/file%v.txt", i%5, i)] = "content"
+	}
+	dir := writeTdfs(t, files)
+	defer os.RemoveAll(dir)
+
+	opts := Options{Jobs: 4}
+	walked, _ := Walk(dir, "", "", opts)
+	// A file listed twice is transformed twice
+	walked = append(walked, walked[0])
+
+	p := []Procedure{Procedure{Name: "Insert", Params: []string{" foo"}}}
+	tr := T{Transformations: []Transformation{Transformation{Filter: "*.txt", Proc: p}}}
+	if count := len(Fix(walked, tr, opts).Files); count != len(walked) {
+		t.Errorf("Fix: %v files should be processed but found %v", len(walked), count)
+	}
+
+	for i, f := range walked {
+		expected := "content foo"
+		if i == 0 || i == len(walked)-1 {
+			expected += " foo"
+		}
+		if content := readContent(t, f); content != expected {
+			t.Errorf("%s should contain %q but found %q", f, expected, content)
+		}
+	}
+}
+
+func TestRunFilesOrder(t *testing.T) {
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("file%v", i))
+	}
+	matched, errs := runFiles(files, Options{Jobs: 8}, func(filePath string, out io.Writer) (bool, error) {
+		if strings.HasSuffix(filePath, "5") {
+			return false, &TransformError{File: filePath, Err: errors.New("failed")}
+		}
+		return strings.HasSuffix(filePath, "0"), nil
+	})
+	if len(matched) != 10 || matched[0] != "file0" || matched[9] != "file90" {
+		t.Errorf("The matched files should be returned in order but found %v", matched)
+	}
+	if len(errs) != 10 || errs[0].(*TransformError).File != "file5" || errs[9].(*TransformError).File != "file95" {
+		t.Errorf("The errors should be returned in order but found %v", errs)
+	}
+}
+
+func TestProcessFilesWithErrors(t *testing.T) {
+	dir := writeTdfs(t, map[string]string{"a.txt": "foo", "b.txt": "foobar", "c.txt": "foo"})
+	defer os.RemoveAll(dir)
+
+	// RemoveAtEnd fails on the files shorter than its parameter
+	p := []Procedure{Procedure{Name: "RemoveAtEnd", Params: []string{"bar"}}, Procedure{Name: "Insert", Params: []string{"!"}}}
+	tr := T{Transformations: []Transformation{Transformation{Filter: "*.txt"}, Transformation{Filter: "*.txt", Proc: p}}}
+	files := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")}
+	if err := ioutil.WriteFile(files[0], []byte("fo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := Fix(files, tr, Options{})
+	count, errs := len(res.Files), res.Errors
+	if count != 2 || len(errs) != 1 {
+		t.Fatalf("Fix: 2 files should be processed and 1 should fail but found %v, %v", count, errs)
+	}
+	e, ok := errs[0].(*TransformError)
+	if !ok || e.File != files[0] || e.Transformation != 2 || e.Procedure != "RemoveAtEnd" {
+		t.Errorf("The error should locate the failure but found %#v", errs[0])
+	}
+	if readContent(t, files[0]) != "fo" || readContent(t, files[1]) != "foo!" || readContent(t, files[2]) != "!" {
+		t.Errorf("Only the failed file should be left unmodified")
+	}
+}